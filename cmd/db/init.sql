@@ -1,33 +1,5 @@
--- Switch to using the `snippetbox` database.
-USE snippetbox;
--- Create a `snippets` table.
-CREATE TABLE snippets (
-    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
-    title VARCHAR(100) NOT NULL,
-    content TEXT NOT NULL,
-    created DATETIME NOT NULL,
-    expires DATETIME NOT NULL
-);
--- Add an index on the created column.
-CREATE INDEX idx_snippets_created ON snippets(created);
-
--- Create a `sessions` table
-CREATE TABLE sessions (
-    token CHAR(43) PRIMARY KEY,
-    data BLOB NOT NULL,
-    expiry TIMESTAMP(6) NOT NULL
-);
-CREATE INDEX sessions_expiry_idx ON sessions (expiry);
-
--- Create a `users` table
-CREATE TABLE users (
-    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
-    name VARCHAR(255) NOT NULL,
-    email VARCHAR(255) NOT NULL,
-    hashed_password CHAR(60) NOT NULL,
-    created DATETIME NOT NULL
-);
-ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+-- The tables of the `snippetbox` database are created by the migrations of the
+-- web server, see the README.
 
 -- Create test DB
 CREATE DATABASE test_snippetbox CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
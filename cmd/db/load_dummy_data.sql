@@ -1,21 +1,35 @@
+-- Add a dummy user owning the snippets below (password: pa$$word).
+INSERT INTO users (name, email, hashed_password, created) VALUES (
+    'Alice Jones',
+    'alice@example.com',
+    '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
+    UTC_TIMESTAMP()
+);
+
 -- Add some dummy records (which we'll use in the next couple of chapters).
-INSERT INTO snippets (title, content, created, expires) VALUES (
+INSERT INTO snippets (user_id, title, content, slug, created, expires) VALUES (
+    (SELECT id FROM users WHERE email = 'alice@example.com'),
     'An old silent pond',
     'An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.\n\n- Matsuo Bashō',
+    'xPb3Y8K0r2mDq9sVnT4wLe',
     UTC_TIMESTAMP(),
     DATE_ADD(UTC_TIMESTAMP(), INTERVAL 365 DAY)
 );
 
-INSERT INTO snippets (title, content, created, expires) VALUES (
+INSERT INTO snippets (user_id, title, content, slug, created, expires) VALUES (
+    (SELECT id FROM users WHERE email = 'alice@example.com'),
     'Over the wintry forest',
     'Over the wintry\nforest, winds howl in rage\nwith no leaves to blow.\n\n- Natsume Soseki',
+    'G7hJ2kLm9NpQ4rSt6UvWxY',
     UTC_TIMESTAMP(),
     DATE_ADD(UTC_TIMESTAMP(), INTERVAL 365 DAY)
 );
 
-INSERT INTO snippets (title, content, created, expires) VALUES (
+INSERT INTO snippets (user_id, title, content, slug, created, expires) VALUES (
+    (SELECT id FROM users WHERE email = 'alice@example.com'),
     'First autumn morning',
     'First autumn morning\nthe mirror I stare into\nshows my father''s face.\n\n- Murakami Kijo',
+    'a1B2c3D4e5F6g7H8i9J0kL',
     UTC_TIMESTAMP(),
     DATE_ADD(UTC_TIMESTAMP(), INTERVAL 7 DAY)
 );
\ No newline at end of file
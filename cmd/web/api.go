@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"snippetbox.flaviogalon.github.io/internal/models"
+	"snippetbox.flaviogalon.github.io/internal/validator"
+)
+
+// JSON API handlers, mounted under /api/v1/. They share the forms, validation
+// and models with the HTML handlers but answer every request with JSON.
+
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	qs := r.URL.Query()
+	filter := readSnippetFilter(qs, &v)
+	if !v.Valid() {
+		app.apiValidationError(w, r, v)
+		return
+	}
+
+	page, err := app.snippetModel.List(r.Context(), filter)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	// Listings carry the snippet content, which must stay hidden for the
+	// protected snippets the client hasn't unlocked, the expired ones it
+	// didn't write and can't use up the views of view limited ones
+	snippets := make([]*models.Snippet, len(page.Snippets))
+	for i, snippet := range page.Snippets {
+		snippet = app.apiSnippet(r, snippet)
+		if app.isLocked(r, snippet) || app.hidesExpired(r, snippet) || app.consumesView(r, snippet) {
+			snippet.Content = ""
+		}
+		snippets[i] = snippet
+	}
+
+	app.writeJSON(w, r, http.StatusOK, envelope{
+		"snippets": snippets,
+		"next":     pageURL("/api/v1/snippets", qs, "after", page.Next),
+		"prev":     pageURL("/api/v1/snippets", qs, "before", page.Prev),
+	})
+}
+
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.viewableSnippet(r)
+	if err != nil {
+		app.apiSnippetError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, envelope{"snippet": app.apiSnippet(r, snippet)})
+}
+
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var form snippetCreateForm
+
+	err := app.readJSON(w, r, &form)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form.validate(app.appConfig.maxExpiry)
+
+	if !form.Valid() {
+		app.apiValidationError(w, r, form.Validator)
+		return
+	}
+
+	id, err := app.snippetModel.Insert(
+		r.Context(),
+		app.authenticatedUserID(r),
+		form.fields(),
+		form.options(),
+	)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.metrics.snippetCreated()
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+	app.writeJSON(w, r, http.StatusCreated, envelope{"id": id})
+}
+
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.ownedSnippet(r)
+	if err != nil {
+		app.apiSnippetError(w, r, err)
+		return
+	}
+
+	var form snippetEditForm
+
+	err = app.readJSON(w, r, &form)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		app.apiValidationError(w, r, form.Validator)
+		return
+	}
+
+	err = app.snippetModel.Update(r.Context(), snippet.ID, form.fields())
+	if err != nil {
+		app.apiSnippetError(w, r, err)
+		return
+	}
+
+	snippet, err = app.snippetModel.Peek(r.Context(), snippet.ID)
+	if err != nil {
+		app.apiSnippetError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, envelope{"snippet": snippet})
+}
+
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.ownedSnippet(r)
+	if err != nil {
+		app.apiSnippetError(w, r, err)
+		return
+	}
+
+	err = app.snippetModel.Delete(r.Context(), snippet.ID)
+	if err != nil {
+		app.apiSnippetError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiWhoami(w http.ResponseWriter, r *http.Request) {
+	user, err := app.userModel.Get(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, r, http.StatusUnauthorized, "authentication required")
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, envelope{"user": user})
+}
+
+// Return a copy of the snippet to send to the client. The slug is left out
+// unless the client is the author: a public snippet made unlisted gets a new
+// one, which must not leak through the listings either.
+func (app *application) apiSnippet(r *http.Request, snippet *models.Snippet) *models.Snippet {
+	c := *snippet
+	if c.UserID != app.authenticatedUserID(r) {
+		c.Slug = ""
+	}
+	return &c
+}
+
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	app.apiError(w, r, http.StatusNotFound, "resource not found")
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+
+	"snippetbox.flaviogalon.github.io/internal/logging"
+	"snippetbox.flaviogalon.github.io/internal/models"
+)
+
+// Prefix of the environment variables setting the configuration: the
+// max-expiry setting is read from SNIPPETBOX_MAX_EXPIRY
+const envPrefix = "SNIPPETBOX_"
+
+// Flags driving how the configuration is loaded rather than settings
+var metaFlags = []string{"config", "print-config"}
+
+// Settings whose value is redacted by -print-config
+var secretSettings = []string{"dsn"}
+
+type appConfig struct {
+	addr             string
+	staticAssertsDir string
+	debugMode        bool
+	// Database backend: mysql, sqlite, postgres or memory
+	store string
+	// Data source name of the store
+	dsn string
+	// Apply the pending schema migrations on start up
+	autoMigrate bool
+	// Longest time a database query may take
+	queryTimeout time.Duration
+	// Database connection pool
+	pool    poolConfig
+	tlsCert string
+	tlsKey  string
+	// Lifetime of the login sessions
+	sessionLifetime time.Duration
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	idleTimeout     time.Duration
+	// Work factor of the bcrypt password hashes
+	bcryptCost int
+	// text or json
+	logFormat string
+	// Longest lifetime of a snippet, 0 allows snippets that never expire
+	maxExpiry time.Duration
+	// Delay between two purges of the expired snippets, 0 disables them
+	janitorInterval time.Duration
+	// How long in-flight requests are given to complete on shutdown
+	shutdownTimeout time.Duration
+	// structured, common or combined
+	accessLogFormat string
+	// Listen address of the /metrics endpoint, empty to disable it
+	metricsAddr string
+
+	// Path of the YAML configuration file
+	configPath string
+	// Dump the effective configuration instead of starting the server
+	printConfig bool
+}
+
+// Return a flag set storing every setting into cfg, with its default value
+func (cfg *appConfig) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("snippetbox", flag.ContinueOnError)
+
+	fs.StringVar(&cfg.addr, "addr", ":4000", "HTTP network address")
+	fs.StringVar(
+		&cfg.staticAssertsDir,
+		"static-dir",
+		"./ui/static/",
+		"Path to static assets",
+	)
+	fs.BoolVar(&cfg.debugMode, "debug", false, "Debug Mode")
+	fs.StringVar(
+		&cfg.store,
+		"store",
+		string(models.MySQL),
+		"Database backend (mysql, sqlite, postgres or memory)",
+	)
+	fs.StringVar(
+		&cfg.dsn,
+		"dsn",
+		"",
+		"Data source name of the store (defaults to a local database named snippetbox)",
+	)
+	fs.BoolVar(
+		&cfg.autoMigrate,
+		"auto-migrate",
+		false,
+		"Apply the pending schema migrations on start up",
+	)
+	fs.DurationVar(
+		&cfg.queryTimeout,
+		"query-timeout",
+		models.DefaultQueryTimeout,
+		"Longest time a database query may take before the request fails with 503",
+	)
+	fs.DurationVar(
+		&cfg.pool.connectTimeout,
+		"db-connect-timeout",
+		30*time.Second,
+		"How long to retry reaching the database on start up (0 to try once)",
+	)
+	fs.IntVar(
+		&cfg.pool.maxOpenConns,
+		"db-max-open-conns",
+		25,
+		"Most open database connections (0 for no limit)",
+	)
+	fs.IntVar(
+		&cfg.pool.maxIdleConns,
+		"db-max-idle-conns",
+		25,
+		"Most idle database connections kept open, ignored by postgres",
+	)
+	fs.DurationVar(
+		&cfg.pool.connMaxLifetime,
+		"db-conn-max-lifetime",
+		time.Hour,
+		"Longest time a database connection is reused (0 for no limit)",
+	)
+	fs.DurationVar(
+		&cfg.pool.connMaxIdleTime,
+		"db-conn-max-idle-time",
+		15*time.Minute,
+		"Longest time a database connection is kept idle (0 for no limit)",
+	)
+	fs.StringVar(&cfg.tlsCert, "tls-cert", "./tls/cert.pem", "Path to the TLS certificate")
+	fs.StringVar(&cfg.tlsKey, "tls-key", "./tls/key.pem", "Path to the TLS private key")
+	fs.DurationVar(
+		&cfg.sessionLifetime,
+		"session-lifetime",
+		12*time.Hour,
+		"Lifetime of the login sessions",
+	)
+	fs.DurationVar(&cfg.readTimeout, "read-timeout", 5*time.Second, "HTTP server read timeout")
+	fs.DurationVar(&cfg.writeTimeout, "write-timeout", 10*time.Second, "HTTP server write timeout")
+	fs.DurationVar(&cfg.idleTimeout, "idle-timeout", time.Minute, "HTTP server keep-alive timeout")
+	fs.IntVar(
+		&cfg.bcryptCost,
+		"bcrypt-cost",
+		models.DefaultBcryptCost,
+		"Work factor of the bcrypt password hashes",
+	)
+	fs.StringVar(
+		&cfg.logFormat,
+		"log-format",
+		logging.FormatText,
+		"Log output format (text or json)",
+	)
+	fs.DurationVar(
+		&cfg.maxExpiry,
+		"max-expiry",
+		0,
+		"Longest lifetime of a snippet (0 for no limit)",
+	)
+	fs.DurationVar(
+		&cfg.janitorInterval,
+		"janitor-interval",
+		time.Hour,
+		"Delay between two purges of the expired snippets (0 to disable)",
+	)
+	fs.DurationVar(
+		&cfg.shutdownTimeout,
+		"shutdown-timeout",
+		20*time.Second,
+		"How long in-flight requests are given to complete on shutdown",
+	)
+	fs.StringVar(
+		&cfg.accessLogFormat,
+		"access-log",
+		accessLogStructured,
+		"Access log format (structured, common or combined)",
+	)
+	fs.StringVar(
+		&cfg.metricsAddr,
+		"metrics-addr",
+		"",
+		"Listen address of the Prometheus /metrics endpoint, e.g. localhost:9090 (disabled when empty)",
+	)
+
+	fs.StringVar(
+		&cfg.configPath,
+		"config",
+		"",
+		"Path of a YAML configuration file (also read from "+envPrefix+"CONFIG)",
+	)
+	fs.BoolVar(
+		&cfg.printConfig,
+		"print-config",
+		false,
+		"Print the effective configuration, secrets redacted, and exit",
+	)
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] %s\n\nFlags:\n", fs.Name(), commandUsage)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// Load the configuration from, by increasing priority: the defaults, the
+// YAML configuration file, the environment variables and the command-line
+// arguments. Returns the flag set holding the settings with it.
+func loadConfig(args []string) (*appConfig, *flag.FlagSet, error) {
+	// A first pass only looks for the configuration file, errors are
+	// reported by the second one
+	first := &appConfig{}
+	fs := first.flagSet()
+	fs.SetOutput(io.Discard)
+	fs.Parse(args)
+
+	configPath := first.configPath
+	if configPath == "" {
+		configPath = os.Getenv(envPrefix + "CONFIG")
+	}
+
+	cfg := &appConfig{}
+	fs = cfg.flagSet()
+
+	if configPath != "" {
+		err := loadConfigFile(fs, configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err := loadConfigEnv(fs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = fs.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.dsn == "" && cfg.store != storeMemory {
+		fs.Set("dsn", defaultDSN(models.Dialect(cfg.store)))
+	}
+
+	return cfg, fs, nil
+}
+
+// Apply the settings of a YAML file made of "setting: value" lines, named
+// after the flags
+func loadConfigFile(fs *flag.FlagSet, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	settings := map[string]any{}
+	err = yaml.Unmarshal(content, &settings)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if fs.Lookup(name) == nil || slices.Contains(metaFlags, name) {
+			return fmt.Errorf("config file %s: unknown setting %q", path, name)
+		}
+
+		value := fmt.Sprint(settings[name])
+		if settings[name] == nil {
+			value = ""
+		}
+		err = fs.Set(name, value)
+		if err != nil {
+			return fmt.Errorf("config file %s: invalid value %q for %s: %w", path, value, name, err)
+		}
+	}
+
+	return nil
+}
+
+// Apply the settings found in the environment variables
+func loadConfigEnv(fs *flag.FlagSet) error {
+	var errs []error
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if slices.Contains(metaFlags, f.Name) {
+			return
+		}
+
+		name := envVarName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+
+		err := fs.Set(f.Name, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("environment variable %s: invalid value %q: %w", name, value, err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// Return the name of the environment variable holding a setting
+func envVarName(setting string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(setting, "-", "_"))
+}
+
+// Check the settings, reporting every invalid one
+func (cfg *appConfig) validate() error {
+	var errs []error
+	check := func(ok bool, setting, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]any{setting}, args...)...))
+		}
+	}
+
+	check(cfg.addr != "", "addr", "must not be empty")
+	_, err := models.ParseDialect(cfg.store)
+	check(
+		err == nil || cfg.store == storeMemory,
+		"store", "must be mysql, sqlite, postgres or memory, got %q", cfg.store,
+	)
+	check(cfg.dsn != "" || cfg.store == storeMemory, "dsn", "must not be empty")
+	check(
+		cfg.bcryptCost >= bcrypt.MinCost && cfg.bcryptCost <= bcrypt.MaxCost,
+		"bcrypt-cost", "must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost,
+	)
+	check(cfg.sessionLifetime > 0, "session-lifetime", "must be positive")
+	check(cfg.readTimeout > 0, "read-timeout", "must be positive")
+	check(cfg.writeTimeout > 0, "write-timeout", "must be positive")
+	check(cfg.idleTimeout > 0, "idle-timeout", "must be positive")
+	check(cfg.shutdownTimeout > 0, "shutdown-timeout", "must be positive")
+	check(cfg.queryTimeout > 0, "query-timeout", "must be positive")
+	check(
+		cfg.queryTimeout < cfg.writeTimeout,
+		"query-timeout", "must be shorter than write-timeout for the 503 response to be sent",
+	)
+	check(cfg.pool.connectTimeout >= 0, "db-connect-timeout", "must not be negative")
+	check(cfg.pool.maxOpenConns >= 0, "db-max-open-conns", "must not be negative")
+	check(cfg.pool.maxIdleConns >= 0, "db-max-idle-conns", "must not be negative")
+	check(
+		cfg.pool.maxOpenConns == 0 || cfg.pool.maxIdleConns <= cfg.pool.maxOpenConns,
+		"db-max-idle-conns", "must not exceed db-max-open-conns",
+	)
+	check(cfg.pool.connMaxLifetime >= 0, "db-conn-max-lifetime", "must not be negative")
+	check(cfg.pool.connMaxIdleTime >= 0, "db-conn-max-idle-time", "must not be negative")
+	check(cfg.maxExpiry >= 0, "max-expiry", "must not be negative")
+	check(cfg.janitorInterval >= 0, "janitor-interval", "must not be negative")
+	check(
+		slices.Contains([]string{logging.FormatText, logging.FormatJSON}, cfg.logFormat),
+		"log-format", "must be text or json, got %q", cfg.logFormat,
+	)
+	check(
+		slices.Contains(
+			[]string{accessLogStructured, accessLogCommon, accessLogCombined},
+			cfg.accessLogFormat,
+		),
+		"access-log", "must be structured, common or combined, got %q", cfg.accessLogFormat,
+	)
+
+	_, err = os.Stat(cfg.tlsCert)
+	check(err == nil, "tls-cert", "%v", err)
+	_, err = os.Stat(cfg.tlsKey)
+	check(err == nil, "tls-key", "%v", err)
+
+	return errors.Join(errs...)
+}
+
+// Write the settings as a YAML configuration file, secrets redacted
+func printConfig(w io.Writer, fs *flag.FlagSet) {
+	dialect := models.Dialect(fs.Lookup("store").Value.String())
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if slices.Contains(metaFlags, f.Name) {
+			return
+		}
+
+		value := f.Value.String()
+		if slices.Contains(secretSettings, f.Name) {
+			value = redactDSN(dialect, value)
+		}
+		fmt.Fprintf(w, "%s: %q\n", f.Name, value)
+	})
+}
+
+// Hide the password of a data source name
+func redactDSN(dialect models.Dialect, dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+
+	switch dialect {
+	case models.SQLite:
+		// A file name, which holds no secret
+		return dsn
+	case models.Postgres:
+		// Either a postgres:// URL or "key=value" settings
+		if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), "REDACTED")
+			}
+			return u.String()
+		}
+		return pgPasswordRX.ReplaceAllString(dsn, "${1}REDACTED")
+	default:
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return "REDACTED"
+		}
+		if cfg.Passwd != "" {
+			cfg.Passwd = "REDACTED"
+		}
+		return cfg.FormatDSN()
+	}
+}
+
+// Matches the password of a "key=value" PostgreSQL connection string
+var pgPasswordRX = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+	"snippetbox.flaviogalon.github.io/internal/models"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "snippetbox.yaml")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+addr: ":5000"
+max-expiry: 48h
+bcrypt-cost: 10
+session-lifetime: 1h
+`)
+
+	t.Setenv("SNIPPETBOX_MAX_EXPIRY", "24h")
+	t.Setenv("SNIPPETBOX_SESSION_LIFETIME", "2h")
+
+	cfg, _, err := loadConfig([]string{"-config", path, "-session-lifetime", "3h"})
+	assert.NilError(t, err)
+
+	// Defaults < file < environment < flags
+	assert.Equal(t, cfg.writeTimeout, 10*time.Second)
+	assert.Equal(t, cfg.addr, ":5000")
+	assert.Equal(t, cfg.bcryptCost, 10)
+	assert.Equal(t, cfg.maxExpiry, 24*time.Hour)
+	assert.Equal(t, cfg.sessionLifetime, 3*time.Hour)
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	path := writeConfigFile(t, `log-format: json`)
+	t.Setenv("SNIPPETBOX_CONFIG", path)
+
+	cfg, _, err := loadConfig(nil)
+	assert.NilError(t, err)
+
+	assert.Equal(t, cfg.logFormat, "json")
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		env       string
+		wantError string
+	}{
+		{
+			name:      "Unknown setting",
+			file:      `adress: ":5000"`,
+			wantError: `unknown setting "adress"`,
+		},
+		{
+			name:      "Invalid file value",
+			file:      `read-timeout: soon`,
+			wantError: `invalid value "soon" for read-timeout`,
+		},
+		{
+			name:      "Invalid environment value",
+			env:       "many",
+			wantError: `environment variable SNIPPETBOX_BCRYPT_COST: invalid value "many"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []string
+			if tt.file != "" {
+				args = []string{"-config", writeConfigFile(t, tt.file)}
+			}
+			if tt.env != "" {
+				t.Setenv("SNIPPETBOX_BCRYPT_COST", tt.env)
+			}
+
+			_, _, err := loadConfig(args)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			assert.StringContains(t, err.Error(), tt.wantError)
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cert := writeConfigFile(t, "")
+	cfg, _, err := loadConfig([]string{"-tls-cert", cert, "-tls-key", cert})
+	assert.NilError(t, err)
+	assert.NilError(t, cfg.validate())
+
+	cfg.bcryptCost = 99
+	cfg.accessLogFormat = "verbose"
+	cfg.tlsCert = "missing.pem"
+	cfg.queryTimeout = cfg.writeTimeout
+	cfg.pool.maxIdleConns = cfg.pool.maxOpenConns + 1
+
+	err = cfg.validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	assert.StringContains(t, err.Error(), "bcrypt-cost: must be between 4 and 31")
+	assert.StringContains(t, err.Error(), `access-log: must be structured, common or combined, got "verbose"`)
+	assert.StringContains(t, err.Error(), "tls-cert: stat missing.pem")
+	assert.StringContains(t, err.Error(), "query-timeout: must be shorter than write-timeout")
+	assert.StringContains(t, err.Error(), "db-max-idle-conns: must not exceed db-max-open-conns")
+}
+
+func TestPrintConfig(t *testing.T) {
+	_, flags, err := loadConfig([]string{"-dsn", "web:s3cret@tcp(db:3306)/snippetbox?parseTime=true"})
+	assert.NilError(t, err)
+
+	var buffer bytes.Buffer
+	printConfig(&buffer, flags)
+
+	assert.StringContains(t, buffer.String(), `dsn: "web:REDACTED@tcp(db:3306)/snippetbox?parseTime=true"`+"\n")
+	assert.StringContains(t, buffer.String(), `max-expiry: "0s"`+"\n")
+	assert.Equal(t, bytes.Contains(buffer.Bytes(), []byte("s3cret")), false)
+	assert.Equal(t, bytes.Contains(buffer.Bytes(), []byte("print-config")), false)
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect models.Dialect
+		dsn     string
+		want    string
+	}{
+		{
+			name:    "MySQL",
+			dialect: models.MySQL,
+			dsn:     "web:s3cret@tcp(db:3306)/snippetbox?parseTime=true",
+			want:    "web:REDACTED@tcp(db:3306)/snippetbox?parseTime=true",
+		},
+		{
+			name:    "PostgreSQL URL",
+			dialect: models.Postgres,
+			dsn:     "postgres://web:s3cret@db:5432/snippetbox",
+			want:    "postgres://web:REDACTED@db:5432/snippetbox",
+		},
+		{
+			name:    "PostgreSQL settings",
+			dialect: models.Postgres,
+			dsn:     "host=db user=web password='s3 cret' dbname=snippetbox",
+			want:    "host=db user=web password=REDACTED dbname=snippetbox",
+		},
+		{
+			name:    "PostgreSQL without password",
+			dialect: models.Postgres,
+			dsn:     "postgres:///snippetbox",
+			want:    "postgres:///snippetbox",
+		},
+		{
+			name:    "SQLite",
+			dialect: models.SQLite,
+			dsn:     "file:snippetbox.db?_pragma=foreign_keys(1)",
+			want:    "file:snippetbox.db?_pragma=foreign_keys(1)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, redactDSN(tt.dialect, tt.dsn), tt.want)
+		})
+	}
+}
+
+func TestLoadConfigDefaultDSN(t *testing.T) {
+	cfg, _, err := loadConfig([]string{"-store", "sqlite"})
+	assert.NilError(t, err)
+
+	assert.Equal(t, cfg.dsn, defaultDSN(models.SQLite))
+}
@@ -4,4 +4,6 @@ const (
 	TOKEN_AUTHENTICATED_USER_ID string = "authenticatedUserID"
 	TOKEN_FLASH                 string = "flash"
 	TOKEN_REDIRECT_PATH         string = "redirectPathAfterLogin"
+	TOKEN_NEW_API_TOKEN         string = "newAPIToken"
+	TOKEN_UNLOCKED_SNIPPET      string = "unlockedSnippet"
 )
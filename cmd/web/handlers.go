@@ -3,8 +3,11 @@ package main
 import (
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
-	"strconv"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 
@@ -13,10 +16,122 @@ import (
 )
 
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
-	validator.Validator `       form:"-"`
+	Title               string `form:"title"      json:"title"`
+	Content             string `form:"content"    json:"content"`
+	Language            string `form:"language"   json:"language"`
+	Format              string `form:"format"     json:"format"`
+	Visibility          string `form:"visibility" json:"visibility"`
+	Expires             string `form:"expires"    json:"expires"`
+	ExpiresAt           string `form:"expiresAt"  json:"expires_at"`
+	Password            string `form:"password"   json:"password"`
+	MaxViews            int    `form:"maxViews"   json:"max_views"`
+	validator.Validator `       form:"-"          json:"-"`
+	// Resolved by validate from Expires or ExpiresAt
+	expiry time.Time
+}
+
+type snippetEditForm struct {
+	Title               string `form:"title"      json:"title"`
+	Content             string `form:"content"    json:"content"`
+	Language            string `form:"language"   json:"language"`
+	Format              string `form:"format"     json:"format"`
+	Visibility          string `form:"visibility" json:"visibility"`
+	validator.Validator `       form:"-"          json:"-"`
+}
+
+func (form *snippetCreateForm) fields() models.SnippetFields {
+	return models.SnippetFields{
+		Title:      form.Title,
+		Content:    form.Content,
+		Language:   form.Language,
+		Format:     snippetFormat(form.Format),
+		Visibility: snippetVisibility(form.Visibility),
+	}
+}
+
+// Data validation, shared by the HTML and JSON handlers. Snippets can't
+// outlive maxExpiry unless it's 0.
+func (form *snippetCreateForm) validate(maxExpiry time.Duration) {
+	checkSnippetFields(&form.Validator, form.fields())
+	form.validateExpiry(time.Now().UTC(), maxExpiry)
+	// bcrypt ignores anything past 72 bytes
+	form.CheckField(
+		len(form.Password) <= 72,
+		"password",
+		"This field can't be more than 72 bytes long",
+	)
+	form.CheckField(
+		form.MaxViews >= 0 && form.MaxViews <= 1000,
+		"maxViews",
+		"This field must be between 0 and 1000",
+	)
+}
+
+// Resolve the expiry from the explicit date when given, from the lifetime
+// otherwise
+func (form *snippetCreateForm) validateExpiry(now time.Time, maxExpiry time.Duration) {
+	key := "expires"
+
+	switch {
+	case form.ExpiresAt != "":
+		key = "expiresAt"
+		expiry, err := parseExpiresAt(form.ExpiresAt)
+		if err != nil {
+			form.AddFieldError(key, "This field must be a date and time")
+			return
+		}
+		if !expiry.After(now) {
+			form.AddFieldError(key, "This field must be in the future")
+			return
+		}
+		form.expiry = expiry
+	case form.Expires == expiresNever:
+		form.expiry = time.Time{}
+	default:
+		lifetime, err := parseLifetime(form.Expires)
+		if errors.Is(err, errLifetimeTooLong) {
+			form.AddFieldError(
+				key,
+				fmt.Sprintf("Snippets can't be kept for more than %s", formatLifetime(maxLifetime)),
+			)
+			return
+		}
+		if err != nil || lifetime < time.Minute {
+			form.AddFieldError(key, "This field must be never or a lifetime such as 30m, 12h or 7d")
+			return
+		}
+		form.expiry = now.Add(lifetime)
+	}
+
+	if maxExpiry > 0 {
+		form.CheckField(
+			!form.expiry.IsZero() && !form.expiry.After(now.Add(maxExpiry)),
+			key,
+			fmt.Sprintf("Snippets can't be kept for more than %s", formatLifetime(maxExpiry)),
+		)
+	}
+}
+
+func (form *snippetCreateForm) options() models.SnippetOptions {
+	return models.SnippetOptions{
+		Expires:  form.expiry,
+		Password: form.Password,
+		MaxViews: form.MaxViews,
+	}
+}
+
+func (form *snippetEditForm) fields() models.SnippetFields {
+	return models.SnippetFields{
+		Title:      form.Title,
+		Content:    form.Content,
+		Language:   form.Language,
+		Format:     snippetFormat(form.Format),
+		Visibility: snippetVisibility(form.Visibility),
+	}
+}
+
+func (form *snippetEditForm) validate() {
+	checkSnippetFields(&form.Validator, form.fields())
 }
 
 type userSignupForm struct {
@@ -39,46 +154,99 @@ type accountPasswordUpdateForm struct {
 	validator.Validator  `       form:"-"`
 }
 
+type snippetUnlockForm struct {
+	Ref                 string `form:"-"`
+	Password            string `form:"password"`
+	validator.Validator `       form:"-"`
+}
+
+type accountTokenForm struct {
+	Name                string `form:"name"`
+	validator.Validator `       form:"-"`
+}
+
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippetModel.Latest()
+	page, err := app.snippetModel.List(r.Context(), models.SnippetFilter{})
 	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
-		} else {
-			app.serverError(w, err)
-		}
+		app.serverError(w, r, err)
 		return
 	}
 
 	templateData := app.newTemplateData(r)
-	templateData.Snippets = snippets
+	templateData.Snippets = page.Snippets
+	templateData.NextPage = pageURL("/snippets", url.Values{}, "after", page.Next)
 
 	app.render(
 		w,
+		r,
 		http.StatusOK,
 		"home.tmpl.html",
 		templateData,
 	)
 }
 
-// Display a single snippet handler
-func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	params := httprouter.ParamsFromContext(r.Context())
-
-	id, err := strconv.Atoi(params.ByName("id"))
+// Browse all the snippets, one page at a time
+func (app *application) snippetList(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
 
-	if err != nil || id < 1 {
-		app.notFound(w)
+	qs := r.URL.Query()
+	filter := readSnippetFilter(qs, &v)
+	if !v.Valid() {
+		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	snippet, err := app.snippetModel.Get(id)
+	page, err := app.snippetModel.List(r.Context(), filter)
 	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
-		} else {
-			app.serverError(w, err)
+		app.serverError(w, r, err)
+		return
+	}
+
+	templateData := app.newTemplateData(r)
+	templateData.Snippets = page.Snippets
+	templateData.Filter = filter
+	templateData.NextPage = pageURL("/snippets", qs, "after", page.Next)
+	templateData.PrevPage = pageURL("/snippets", qs, "before", page.Prev)
+
+	app.render(w, r, http.StatusOK, "snippets.tmpl.html", templateData)
+}
+
+// Full-text search over the snippet titles and contents
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if !validator.MaxChars(query, 100) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	templateData := app.newTemplateData(r)
+	templateData.Query = query
+
+	if query != "" {
+		snippets, err := app.snippetModel.Search(r.Context(), query, models.MaxPageSize)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
 		}
+		templateData.Snippets = snippets
+	}
+
+	app.render(w, r, http.StatusOK, "search.tmpl.html", templateData)
+}
+
+// Display a single snippet handler
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.viewableSnippet(r)
+	if errors.Is(err, errSnippetLocked) {
+		data := app.newTemplateData(r)
+		data.Form = snippetUnlockForm{
+			Ref: httprouter.ParamsFromContext(r.Context()).ByName("id"),
+		}
+		app.render(w, r, http.StatusOK, "unlock.tmpl.html", data)
+		return
+	}
+	if err != nil {
+		app.snippetError(w, r, err)
 		return
 	}
 
@@ -87,18 +255,115 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 
 	app.render(
 		w,
+		r,
 		http.StatusOK,
 		"view.tmpl.html",
 		templateData,
 	)
 }
 
+// Check the password of a protected snippet and remember in the session that
+// the visitor may read it
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.findSnippet(r)
+	if err != nil {
+		app.snippetError(w, r, err)
+		return
+	}
+
+	form := snippetUnlockForm{
+		Ref: httprouter.ParamsFromContext(r.Context()).ByName("id"),
+	}
+	viewURL := fmt.Sprintf("/snippet/view/%s", form.Ref)
+
+	if !app.isLocked(r, snippet) {
+		http.Redirect(w, r, viewURL, http.StatusSeeOther)
+		return
+	}
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Guessing the password is slowed down by a lockout after a few wrong
+	// ones. The password isn't checked at all while it lasts.
+	if app.unlockLimiter.lockedOut(snippet.ID) {
+		form.AddFieldError("password", "Too many wrong passwords, try again later")
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusTooManyRequests, "unlock.tmpl.html", data)
+		return
+	}
+
+	form.CheckField(
+		validator.NotBlank(form.Password),
+		"password",
+		"This field can't be blank",
+	)
+	if form.Valid() {
+		err = snippet.CheckPassword(form.Password)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, r, err)
+				return
+			}
+			app.unlockLimiter.recordFailure(snippet.ID)
+			form.AddFieldError("password", "Wrong password")
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "unlock.tmpl.html", data)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), unlockedSnippetKey(snippet.ID), true)
+
+	http.Redirect(w, r, viewURL, http.StatusSeeOther)
+}
+
+// Send the snippet content as plain text
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.viewableSnippet(r)
+	if err != nil {
+		app.snippetError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(snippet.Content))
+}
+
+// Send the snippet content as a file attachment
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.viewableSnippet(r)
+	if err != nil {
+		app.snippetError(w, r, err)
+		return
+	}
+
+	disposition := mime.FormatMediaType(
+		"attachment",
+		map[string]string{"filename": snippetFilename(snippet)},
+	)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", disposition)
+	w.Write([]byte(snippet.Content))
+}
+
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Format:     models.FormatPlain,
+		Visibility: models.VisibilityPublic,
+		Expires:    "365d",
 	}
-	app.render(w, http.StatusOK, "create.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "create.tmpl.html", data)
 }
 
 // Create a snippet handler
@@ -111,54 +376,109 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Data validation
-	form.CheckField(
-		validator.NotBlank(form.Title),
-		"title",
-		"This field can't be blank",
-	)
-	form.CheckField(
-		validator.MaxChars(form.Title, 100),
-		"title",
-		"This field can't be more than 100 characters long",
-	)
-	form.CheckField(
-		validator.NotBlank(form.Content),
-		"content",
-		"This field can't be blank",
-	)
-	form.CheckField(
-		validator.PermittedValue(form.Expires, 1, 7, 365),
-		"expires",
-		"This field must be equal 1, 7 or 365",
-	)
+	form.validate(app.appConfig.maxExpiry)
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl.html", data)
 		return
 	}
 
 	id, err := app.snippetModel.Insert(
-		form.Title,
-		form.Content,
-		form.Expires,
+		r.Context(),
+		app.authenticatedUserID(r),
+		form.fields(),
+		form.options(),
 	)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
+	app.metrics.snippetCreated()
 
 	app.sessionManager.Put(r.Context(), TOKEN_FLASH, "Snippet successfully created!")
 
 	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
 }
 
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.ownedSnippet(r)
+	if err != nil {
+		app.snippetError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetEditForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Language:   snippet.Language,
+		Format:     snippet.Format,
+		Visibility: snippet.Visibility,
+	}
+	app.render(w, r, http.StatusOK, "edit.tmpl.html", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.ownedSnippet(r)
+	if err != nil {
+		app.snippetError(w, r, err)
+		return
+	}
+
+	var form snippetEditForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl.html", data)
+		return
+	}
+
+	err = app.snippetModel.Update(r.Context(), snippet.ID, form.fields())
+	if err != nil {
+		app.snippetError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), TOKEN_FLASH, "Snippet successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.ownedSnippet(r)
+	if err != nil {
+		app.snippetError(w, r, err)
+		return
+	}
+
+	err = app.snippetModel.Delete(r.Context(), snippet.ID)
+	if err != nil {
+		app.snippetError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), TOKEN_FLASH, "Snippet successfully deleted!")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userSignupForm{}
-	app.render(w, http.StatusOK, "signup.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "signup.tmpl.html", data)
 }
 
 func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
@@ -199,20 +519,20 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
 		return
 	}
 
-	err = app.userModel.Insert(form.Name, form.Email, form.Password)
+	err = app.userModel.Insert(r.Context(), form.Name, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email address is already in use")
 
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
@@ -229,7 +549,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userLoginForm{}
-	app.render(w, http.StatusOK, "login.tmpl.html", data)
+	app.render(w, r, http.StatusOK, "login.tmpl.html", data)
 }
 
 func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
@@ -260,27 +580,30 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl.html", data)
 		return
 	}
 
-	id, err := app.userModel.Authenticate(form.Email, form.Password)
+	id, err := app.userModel.Authenticate(r.Context(), form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.metrics.login(false)
 			form.AddNonFieldError("Email or password is incorrect")
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl.html", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	app.metrics.login(true)
+
 	// Renew session token for the user, but keeping any data already there
 	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -300,7 +623,7 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	// Renew the session token to invalidate the previous one
 	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -315,6 +638,7 @@ func (app *application) about(w http.ResponseWriter, r *http.Request) {
 
 	app.render(
 		w,
+		r,
 		http.StatusOK,
 		"about.tmpl.html",
 		templateData,
@@ -322,22 +646,108 @@ func (app *application) about(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
-	id := app.sessionManager.GetInt(r.Context(), TOKEN_AUTHENTICATED_USER_ID)
+	app.renderAccount(w, r, http.StatusOK, accountTokenForm{})
+}
 
-	user, err := app.userModel.Get(id)
+// Render the account page, which lists the user's snippets and API tokens
+// next to the token creation form
+func (app *application) renderAccount(
+	w http.ResponseWriter,
+	r *http.Request,
+	statusCode int,
+	form accountTokenForm,
+) {
+	id := app.authenticatedUserID(r)
+
+	user, err := app.userModel.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	snippets, err := app.snippetModel.ListByUser(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	tokens, err := app.tokenModel.ListByUser(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	templateData := app.newTemplateData(r)
 	templateData.User = user
+	templateData.Snippets = snippets
+	templateData.Tokens = tokens
+	templateData.NewToken = app.sessionManager.PopString(r.Context(), TOKEN_NEW_API_TOKEN)
+	templateData.Form = form
+
+	app.render(w, r, statusCode, "account.tmpl.html", templateData)
+}
+
+func (app *application) accountTokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountTokenForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
 
-	app.render(w, http.StatusOK, "account.tmpl.html", templateData)
+	form.CheckField(
+		validator.NotBlank(form.Name),
+		"name",
+		"This field can't be blank",
+	)
+	form.CheckField(
+		validator.MaxChars(form.Name, 100),
+		"name",
+		"This field can't be more than 100 characters long",
+	)
+
+	if !form.Valid() {
+		app.renderAccount(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	token, err := app.tokenModel.New(r.Context(), app.authenticatedUserID(r), form.Name)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The plaintext token is only shown once, on the next account page view
+	app.sessionManager.Put(r.Context(), TOKEN_NEW_API_TOKEN, token.Plaintext)
+	app.sessionManager.Put(r.Context(), TOKEN_FLASH, "API token created. Copy it now, it won't be shown again!")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := readIDParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	err = app.tokenModel.Delete(r.Context(), id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), TOKEN_FLASH, "API token revoked")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
 
 func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
@@ -346,6 +756,7 @@ func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Req
 
 	app.render(
 		w,
+		r,
 		http.StatusOK,
 		"password.tmpl.html",
 		templateData,
@@ -390,21 +801,21 @@ func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "password.tmpl.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl.html", data)
 		return
 	}
 
-	id := app.sessionManager.GetInt(r.Context(), TOKEN_AUTHENTICATED_USER_ID)
+	id := app.authenticatedUserID(r)
 
-	err = app.userModel.PasswordUpdate(id, form.CurrentPassword, form.NewPassword)
+	err = app.userModel.PasswordUpdate(r.Context(), id, form.CurrentPassword, form.NewPassword)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddFieldError("currentPassword", "Current password is incorrect")
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "password.tmpl.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl.html", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
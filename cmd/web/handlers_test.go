@@ -1,11 +1,16 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
+	"slices"
+	"strings"
 	"testing"
 
 	"snippetbox.flaviogalon.github.io/internal/assert"
+	"snippetbox.flaviogalon.github.io/internal/models/mocks"
 )
 
 func TestPing(t *testing.T) {
@@ -62,6 +67,34 @@ func TestSnippetView(t *testing.T) {
 			urlPath:  "/snippet/view/",
 			wantCode: http.StatusNotFound,
 		},
+		{
+			name:     "Unlisted by ID",
+			urlPath:  "/snippet/view/3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Unlisted by slug",
+			urlPath:  "/snippet/view/G7hJ2kLm9NpQ4rSt6UvWxY",
+			wantCode: http.StatusOK,
+			wantBody: "Over the wintry forest...",
+		},
+		{
+			name:     "Last view",
+			urlPath:  "/snippet/view/5",
+			wantCode: http.StatusOK,
+			wantBody: "This was the last view of this snippet",
+		},
+		{
+			name:     "Database timeout",
+			urlPath:  "/snippet/view/6",
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "Service Unavailable",
+		},
+		{
+			name:     "Unknown slug",
+			urlPath:  "/snippet/view/AAAAAAAAAAAAAAAAAAAAAA",
+			wantCode: http.StatusNotFound,
+		},
 	}
 
 	for _, tt := range tests {
@@ -77,6 +110,177 @@ func TestSnippetView(t *testing.T) {
 	}
 }
 
+func TestSnippetViewOwner(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	// Authors can reach their unlisted snippets by ID
+	code, _, body := ts.get(t, "/snippet/view/3")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Over the wintry forest...")
+	assert.StringContains(t, body, "/snippet/view/G7hJ2kLm9NpQ4rSt6UvWxY")
+}
+
+func TestSnippetUnlock(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/view/4")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This snippet is password protected.")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	code, _, _ = ts.get(t, "/snippet/raw/4")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	tests := []struct {
+		name         string
+		password     string
+		csrfToken    string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:      "Blank password",
+			password:  "",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "This field can&#39;t be blank",
+		},
+		{
+			name:      "Wrong password",
+			password:  "hunter2",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "Wrong password",
+		},
+		{
+			name:      "Invalid CSRF Token",
+			password:  "hunter22",
+			csrfToken: "wrongToken",
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:         "Valid password",
+			password:     "hunter22",
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, header, body := ts.postForm(t, "/snippet/unlock/4", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	// The unlock grant is kept in the session
+	code, _, body = ts.get(t, "/snippet/view/4")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "First autumn morning...")
+
+	code, _, body = ts.get(t, "/snippet/raw/4")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "First autumn morning...")
+}
+
+func TestSnippetUnlockLockout(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		// Drop the session cookie after the wrong passwords
+		newSession   bool
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Below the limit",
+			failures:     maxUnlockFailures - 1,
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/4",
+		},
+		{
+			name:     "Locked out",
+			failures: maxUnlockFailures,
+			wantCode: http.StatusTooManyRequests,
+			wantBody: "Too many wrong passwords, try again later",
+		},
+		{
+			name:       "Locked out in a new session",
+			failures:   maxUnlockFailures,
+			newSession: true,
+			wantCode:   http.StatusTooManyRequests,
+			wantBody:   "Too many wrong passwords, try again later",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			var validCSRFToken string
+			startSession := func() {
+				_, _, body := ts.get(t, "/snippet/view/4")
+				validCSRFToken = extractCSRFToken(t, body)
+			}
+			post := func(password string) (int, http.Header, string) {
+				form := url.Values{}
+				form.Add("password", password)
+				form.Add("csrf_token", validCSRFToken)
+				return ts.postForm(t, "/snippet/unlock/4", form)
+			}
+
+			startSession()
+			for range tt.failures {
+				code, _, body := post("hunter2")
+				assert.Equal(t, code, http.StatusUnprocessableEntity)
+				assert.StringContains(t, body, "Wrong password")
+			}
+
+			if tt.newSession {
+				jar, err := cookiejar.New(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ts.Client().Jar = jar
+				startSession()
+			}
+
+			// Even the right password is refused during the lockout
+			code, header, body := post("hunter22")
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+				code, _, _ = ts.get(t, "/snippet/raw/4")
+				assert.Equal(t, code, http.StatusForbidden)
+			}
+		})
+	}
+}
+
 func TestUserSignup(t *testing.T) {
 	app := newTestApplication(t)
 	ts := newTestServer(t, app.routes())
@@ -229,3 +433,325 @@ func TestSnippetCreate(t *testing.T) {
 		assert.StringContains(t, body, "<form action=\"/snippet/create\" method=\"POST\">")
 	})
 }
+
+func TestAccountView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/account/view")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "My snippets")
+	assert.StringContains(t, body, `<a href="/snippet/view/1">An old silent pond</a>`)
+}
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	t.Run("Owner", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/edit/1")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `<form action="/snippet/edit/1" method="POST">`)
+		assert.StringContains(t, body, `value="An old silent pond"`)
+	})
+
+	t.Run("Non-existent ID", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/edit/2")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Not owner", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/edit/4")
+
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+
+	t.Run("Not owner submission", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/edit/1")
+
+		form := url.Values{}
+		form.Add("title", "Stolen")
+		form.Add("content", "Overwritten by another user")
+		form.Add("format", "plain")
+		form.Add("visibility", "public")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, _, _ := ts.postForm(t, "/snippet/edit/4", form)
+
+		assert.Equal(t, code, http.StatusForbidden)
+		assert.Equal(t, len(app.snippetModel.(*mocks.SnippetModel).UpdatedIDs()), 0)
+	})
+
+	// Expired or deleted between the ownership check and the update
+	t.Run("Gone before the update", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/edit/3")
+
+		form := url.Values{}
+		form.Add("title", "Over the wintry forest")
+		form.Add("content", "Winds howl in rage")
+		form.Add("format", "plain")
+		form.Add("visibility", "unlisted")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, header, _ := ts.postForm(t, "/snippet/edit/3", form)
+
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, header.Get("Location"), "")
+	})
+}
+
+func TestSnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/view/1")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		id           int
+		wantCode     int
+		wantLocation string
+		wantDeleted  bool
+	}{
+		{
+			name:         "Owner",
+			id:           1,
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/view",
+			wantDeleted:  true,
+		},
+		{
+			name:     "Non-existent ID",
+			id:       2,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Not owner",
+			id:       4,
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, header, _ := ts.postForm(t, fmt.Sprintf("/snippet/delete/%d", tt.id), form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+			deleted := app.snippetModel.(*mocks.SnippetModel).DeletedIDs()
+			assert.Equal(t, slices.Contains(deleted, tt.id), tt.wantDeleted)
+		})
+	}
+}
+
+func TestAccountTokenCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	t.Run("Empty name", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("name", "")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, _, body := ts.postForm(t, "/account/tokens/create", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field can&#39;t be blank")
+	})
+
+	t.Run("Valid submission", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("name", "CI")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, header, _ := ts.postForm(t, "/account/tokens/create", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/account/view")
+
+		// The plaintext token is displayed once
+		_, _, body := ts.get(t, "/account/view")
+		assert.StringContains(t, body, "NEWMOCKTOKENNEWMOCKTOKENNEWMOCKT")
+
+		_, _, body = ts.get(t, "/account/view")
+		if strings.Contains(body, "NEWMOCKTOKENNEWMOCKTOKENNEWMOCKT") {
+			t.Error("token displayed more than once")
+		}
+	})
+}
+
+func TestSnippetList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "First page",
+			urlPath:  "/snippets",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Oldest first",
+			urlPath:  "/snippets?sort=oldest&size=25&expired=1",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Past the last page",
+			urlPath:  "/snippets?after=1",
+			wantCode: http.StatusOK,
+			wantBody: "There's nothing to see here... yet!",
+		},
+		{
+			name:     "Invalid cursor",
+			urlPath:  "/snippets?after=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Both cursors",
+			urlPath:  "/snippets?after=1&before=3",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Page too large",
+			urlPath:  "/snippets?size=1000",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Unknown sort",
+			urlPath:  "/snippets?sort=title",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Match",
+			urlPath:  "/search?q=silent",
+			wantCode: http.StatusOK,
+			wantBody: "An old <mark>silent</mark> pond",
+		},
+		{
+			name:     "No match",
+			urlPath:  "/search?q=frog",
+			wantCode: http.StatusOK,
+			wantBody: `No snippets match "frog".`,
+		},
+		{
+			name:     "Empty query",
+			urlPath:  "/search",
+			wantCode: http.StatusOK,
+			wantBody: `<form action="/search" method="GET" class="search">`,
+		},
+		{
+			name:     "Query too long",
+			urlPath:  "/search?q=" + strings.Repeat("a", 101),
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Valid ID", func(t *testing.T) {
+		code, header, body := ts.get(t, "/snippet/raw/1")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+		assert.Equal(t, body, "An old silent pond...")
+	})
+
+	t.Run("Non-existent ID", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/raw/2")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestSnippetDownload(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Valid ID", func(t *testing.T) {
+		code, header, body := ts.get(t, "/snippet/download/1")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, header.Get("Content-Disposition"), `attachment; filename=an-old-silent-pond.txt`)
+		assert.Equal(t, body, "An old silent pond...")
+	})
+
+	t.Run("Non-existent ID", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/download/2")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// Longest time the readiness checks may take altogether
+const readinessTimeout = 2 * time.Second
+
+// Anything that can check its connection to the database, such as *sql.DB
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Liveness probe: the process is up and serving requests
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, r, http.StatusOK, envelope{"status": "ok"})
+}
+
+// Readiness probe: the dependencies needed to serve requests work. Answers
+// 503 when one of the checks fails so that load balancers stop routing
+// traffic to this instance. The errors are only logged, as they may reveal
+// details of the infrastructure to anyone reaching the endpoint.
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"database":  app.checkDatabase,
+		"sessions":  app.checkSessions,
+		"templates": app.checkTemplates,
+	}
+
+	status := "ok"
+	report := envelope{}
+	for name, check := range checks {
+		err := check(ctx)
+		if err != nil {
+			app.logger.WarnContext(ctx, "readiness check failed", "check", name, "error", err)
+			status = "unavailable"
+			report[name] = envelope{"status": "unavailable"}
+		} else {
+			report[name] = envelope{"status": "ok"}
+		}
+	}
+
+	statusCode := http.StatusOK
+	if status != "ok" {
+		statusCode = http.StatusServiceUnavailable
+	}
+	app.writeJSON(w, r, statusCode, envelope{"status": status, "checks": report})
+}
+
+func (app *application) checkDatabase(ctx context.Context) error {
+	if app.db == nil {
+		return errors.New("no database configured")
+	}
+	return app.db.PingContext(ctx)
+}
+
+// Look up a session that can't exist, which makes a round trip to the store
+func (app *application) checkSessions(ctx context.Context) error {
+	if store, ok := app.sessionManager.Store.(scs.CtxStore); ok {
+		_, _, err := store.FindCtx(ctx, "readiness-check")
+		return err
+	}
+	_, _, err := app.sessionManager.Store.Find("readiness-check")
+	return err
+}
+
+func (app *application) checkTemplates(ctx context.Context) error {
+	if len(app.templateCache) == 0 {
+		return errors.New("no template loaded")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+)
+
+type stubPinger struct {
+	err error
+}
+
+func (p stubPinger) PingContext(ctx context.Context) error {
+	return p.err
+}
+
+func TestHealthz(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/healthz")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `{"status":"ok"}`)
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name     string
+		db       pinger
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Ready",
+			db:       stubPinger{},
+			wantCode: http.StatusOK,
+			wantBody: `"database":{"status":"ok"}`,
+		},
+		{
+			name:     "Database down",
+			db:       stubPinger{err: errors.New("connection refused")},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `"database":{"status":"unavailable"}`,
+		},
+		{
+			name:     "No database",
+			db:       nil,
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `"status":"unavailable"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.db = tt.db
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, _, body := ts.get(t, "/readyz")
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+			assert.StringContains(t, body, `"templates":{"status":"ok"}`)
+			assert.StringContains(t, body, `"sessions":{"status":"ok"}`)
+			assert.Equal(t, strings.Contains(body, "connection refused"), false)
+		})
+	}
+}
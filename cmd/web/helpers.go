@@ -2,24 +2,57 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"regexp"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
+
+	"snippetbox.flaviogalon.github.io/internal/models"
+	"snippetbox.flaviogalon.github.io/internal/validator"
+)
+
+var (
+	errNotOwner      = errors.New("snippet belongs to another user")
+	errSnippetLocked = errors.New("snippet is password protected")
 )
 
-// Write an error message and stack trace to custom error logger then sends
-// a 500 response to the user
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.errorLog.Output(2, trace)
+// Maximum accepted size of a JSON request body
+const maxJSONBodyBytes = 1_048_576
+
+// Top-level object of every JSON response
+type envelope map[string]any
+
+// Log an error message and stack trace along with the request then sends a
+// 500 response to the user
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	if isTimeout(err) {
+		app.unavailable(w, r, err)
+		http.Error(
+			w,
+			http.StatusText(http.StatusServiceUnavailable),
+			http.StatusServiceUnavailable,
+		)
+		return
+	}
+
+	trace := string(debug.Stack())
+	app.logError(r, err, trace)
 
 	if app.appConfig.debugMode {
-		http.Error(w, trace, http.StatusInternalServerError)
+		http.Error(w, err.Error()+"\n"+trace, http.StatusInternalServerError)
 		return
 	}
 
@@ -30,6 +63,37 @@ func (app *application) serverError(w http.ResponseWriter, err error) {
 	)
 }
 
+// Report whether err comes from a database query running past its deadline.
+// The database is overloaded or unreachable then, rather than the request
+// being faulty.
+func isTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// Log a query timeout and ask the client to retry later. The 503 status
+// code is left to the caller, which knows the response format.
+func (app *application) unavailable(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.WarnContext(
+		r.Context(),
+		"database query timed out",
+		"error", err,
+		"method", r.Method,
+		"uri", r.URL.RequestURI(),
+	)
+	w.Header().Set("Retry-After", "5")
+}
+
+// Log an error met while serving the request
+func (app *application) logError(r *http.Request, err error, trace string) {
+	app.logger.ErrorContext(
+		r.Context(),
+		err.Error(),
+		"method", r.Method,
+		"uri", r.URL.RequestURI(),
+		"trace", trace,
+	)
+}
+
 // Send a specific status code and corresponding description to caller
 func (app *application) clientError(w http.ResponseWriter, statusCode int) {
 	http.Error(w, http.StatusText(statusCode), statusCode)
@@ -41,6 +105,7 @@ func (app *application) notFound(w http.ResponseWriter) {
 
 func (app *application) render(
 	w http.ResponseWriter,
+	r *http.Request,
 	statusCode int,
 	pageName string,
 	data *templateData,
@@ -48,7 +113,7 @@ func (app *application) render(
 	ts, ok := app.templateCache[pageName]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exist", pageName)
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -57,7 +122,7 @@ func (app *application) render(
 
 	err := ts.ExecuteTemplate(buffer, "base", data)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -69,10 +134,11 @@ func (app *application) render(
 // Create a new template struct and prefill it with useful data
 func (app *application) newTemplateData(r *http.Request) *templateData {
 	return &templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), TOKEN_FLASH),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+		CurrentYear:         time.Now().Year(),
+		Flash:               app.sessionManager.PopString(r.Context(), TOKEN_FLASH),
+		IsAuthenticated:     app.isAuthenticated(r),
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r),
 	}
 }
 
@@ -103,3 +169,467 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// Return the ID of the user authenticated by session or API token, or 0 if
+// there's none
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+	return id
+}
+
+// Return the positive integer held by the :id route parameter or
+// models.ErrNoRecord when it can't reference any record
+func readIDParam(r *http.Request) (int, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		return 0, models.ErrNoRecord
+	}
+	return id, nil
+}
+
+// Fetch the snippet referenced by the :id route parameter for display and
+// check that its content may be read
+func (app *application) viewableSnippet(r *http.Request) (*models.Snippet, error) {
+	snippet, err := app.findSnippet(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.isLocked(r, snippet) {
+		return nil, errSnippetLocked
+	}
+
+	if app.consumesView(r, snippet) {
+		return app.snippetModel.Get(r.Context(), snippet.ID)
+	}
+
+	return snippet, nil
+}
+
+// Fetch the snippet referenced by the :id route parameter. The parameter holds
+// either the numeric ID or the slug of the snippet. IDs are sequential so only
+// public snippets can be reached through them, except by their author.
+func (app *application) findSnippet(r *http.Request) (*models.Snippet, error) {
+	ref := httprouter.ParamsFromContext(r.Context()).ByName("id")
+
+	id, err := strconv.Atoi(ref)
+	if err != nil {
+		return app.snippetModel.PeekBySlug(r.Context(), ref)
+	}
+	if id < 1 {
+		return nil, models.ErrNoRecord
+	}
+
+	snippet, err := app.snippetModel.Peek(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if snippet.Visibility != models.VisibilityPublic &&
+		snippet.UserID != app.authenticatedUserID(r) {
+		return nil, models.ErrNoRecord
+	}
+
+	return snippet, nil
+}
+
+// Report whether the snippet content is hidden behind a password the current
+// visitor hasn't entered yet. Authors can always read their own snippets.
+func (app *application) isLocked(r *http.Request, snippet *models.Snippet) bool {
+	if !snippet.Protected || snippet.UserID == app.authenticatedUserID(r) {
+		return false
+	}
+	return !app.sessionManager.GetBool(r.Context(), unlockedSnippetKey(snippet.ID))
+}
+
+// Report whether reading the snippet content uses up one of its views.
+// Authors don't use up the views of their own snippets.
+func (app *application) consumesView(r *http.Request, snippet *models.Snippet) bool {
+	return snippet.RemainingViews != nil && snippet.UserID != app.authenticatedUserID(r)
+}
+
+// Report whether the snippet expired and its content must be hidden from the
+// current visitor. Listings can include the expired snippets, whose content
+// only their authors may still read.
+func (app *application) hidesExpired(r *http.Request, snippet *models.Snippet) bool {
+	return !snippet.Expires.IsZero() && !snippet.Expires.After(time.Now()) &&
+		snippet.UserID != app.authenticatedUserID(r)
+}
+
+// Session key recording that the visitor entered the password of a snippet
+func unlockedSnippetKey(id int) string {
+	return fmt.Sprintf("%s:%d", TOKEN_UNLOCKED_SNIPPET, id)
+}
+
+// Fetch the snippet referenced by the :id route parameter and check that it
+// belongs to the logged in user
+func (app *application) ownedSnippet(r *http.Request) (*models.Snippet, error) {
+	id, err := readIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	snippet, err := app.snippetModel.Peek(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if snippet.UserID != app.authenticatedUserID(r) {
+		return nil, errNotOwner
+	}
+
+	return snippet, nil
+}
+
+// Send the response matching an error returned while fetching a snippet
+func (app *application) snippetError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.notFound(w)
+	case errors.Is(err, errNotOwner), errors.Is(err, errSnippetLocked):
+		app.clientError(w, http.StatusForbidden)
+	default:
+		app.serverError(w, r, err)
+	}
+}
+
+// Validate the fields shared by the snippet forms
+func checkSnippetFields(v *validator.Validator, fields models.SnippetFields) {
+	v.CheckField(
+		validator.NotBlank(fields.Title),
+		"title",
+		"This field can't be blank",
+	)
+	v.CheckField(
+		validator.MaxChars(fields.Title, 100),
+		"title",
+		"This field can't be more than 100 characters long",
+	)
+	v.CheckField(
+		validator.NotBlank(fields.Content),
+		"content",
+		"This field can't be blank",
+	)
+	v.CheckField(
+		validator.PermittedValue(fields.Language, languageValues()...),
+		"language",
+		"This field must be one of the supported languages",
+	)
+	v.CheckField(
+		validator.PermittedValue(fields.Format, models.FormatPlain, models.FormatMarkdown),
+		"format",
+		"This field must be equal plain or markdown",
+	)
+	v.CheckField(
+		validator.PermittedValue(
+			fields.Visibility,
+			models.VisibilityPublic,
+			models.VisibilityUnlisted,
+			models.VisibilityPrivate,
+		),
+		"visibility",
+		"This field must be equal public, unlisted or private",
+	)
+}
+
+// Value of the expires field for snippets that are kept forever
+const expiresNever = "never"
+
+var lifetimeRX = regexp.MustCompile(`^([0-9]+)([mhdw]?)$`)
+
+var lifetimeUnits = map[string]time.Duration{
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// Longest lifetime parseLifetime accepts, snippets kept longer never expire
+const maxLifetime = 10 * 365 * 24 * time.Hour
+
+var errLifetimeTooLong = fmt.Errorf("lifetime longer than %s", formatLifetime(maxLifetime))
+
+// Parse a snippet lifetime written as a number of minutes, hours, days or
+// weeks: 30m, 12h, 7d or 2w. A number without unit counts days. Lifetimes
+// over maxLifetime are rejected before they can overflow a time.Duration.
+func parseLifetime(value string) (time.Duration, error) {
+	matches := lifetimeRX.FindStringSubmatch(value)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid lifetime %q", value)
+	}
+
+	n, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, err
+	}
+
+	unit := lifetimeUnits["d"]
+	if matches[2] != "" {
+		unit = lifetimeUnits[matches[2]]
+	}
+	if n > int(maxLifetime/unit) {
+		return 0, errLifetimeTooLong
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// Write a lifetime in the largest unit accepted by parseLifetime which
+// divides it
+func formatLifetime(d time.Duration) string {
+	for _, unit := range []string{"w", "d", "h"} {
+		if d%lifetimeUnits[unit] == 0 {
+			return fmt.Sprintf("%d%s", d/lifetimeUnits[unit], unit)
+		}
+	}
+	return fmt.Sprintf("%dm", d/time.Minute)
+}
+
+// Parse an expiry date sent either by a datetime-local input, read as UTC, or
+// as RFC 3339
+func parseExpiresAt(value string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02T15:04", value)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+var nonAlphanumericRX = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Return the name under which a snippet is downloaded, derived from its
+// title, language and format
+func snippetFilename(snippet *models.Snippet) string {
+	name := nonAlphanumericRX.ReplaceAllString(strings.ToLower(snippet.Title), "-")
+	name = strings.Trim(name, "-")
+	if len(name) > 50 {
+		name = strings.TrimRight(name[:50], "-")
+	}
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", snippet.ID)
+	}
+
+	if snippet.Format == models.FormatMarkdown {
+		return name + ".md"
+	}
+	return name + languageExtension(snippet.Language)
+}
+
+// Return the submitted snippet format, defaulting to plain text when omitted
+func snippetFormat(format string) string {
+	if format == "" {
+		return models.FormatPlain
+	}
+	return format
+}
+
+// Return the submitted snippet visibility, defaulting to public when omitted
+func snippetVisibility(visibility string) string {
+	if visibility == "" {
+		return models.VisibilityPublic
+	}
+	return visibility
+}
+
+// Encode data as JSON and send it with the given status code
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, statusCode int, data envelope) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(js)
+	w.Write([]byte("\n"))
+}
+
+// Decode a single JSON object from the request body into dst. The returned
+// errors are meant to be shown to the API client.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errors.New("Content-Type header must be application/json")
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &unmarshalTypeError):
+			return fmt.Errorf("body contains an incorrect JSON type for field %q", unmarshalTypeError.Field)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return err
+		}
+	}
+
+	// The body must contain a single JSON value
+	if decoder.More() {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// Send a JSON error message to the API client
+func (app *application) apiError(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
+	app.writeJSON(w, r, statusCode, envelope{"error": message})
+}
+
+// Log the error then send a generic JSON 500 response
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	if isTimeout(err) {
+		app.unavailable(w, r, err)
+		app.apiError(
+			w,
+			r,
+			http.StatusServiceUnavailable,
+			http.StatusText(http.StatusServiceUnavailable),
+		)
+		return
+	}
+
+	app.logError(r, err, string(debug.Stack()))
+
+	app.apiError(
+		w,
+		r,
+		http.StatusInternalServerError,
+		http.StatusText(http.StatusInternalServerError),
+	)
+}
+
+// JSON names of the fields the HTML forms name differently, so that API
+// clients can match the errors with the fields they sent
+var apiFieldNames = map[string]string{
+	"expiresAt": "expires_at",
+	"maxViews":  "max_views",
+}
+
+// Send the validator's errors to the API client as structured JSON
+func (app *application) apiValidationError(w http.ResponseWriter, r *http.Request, v validator.Validator) {
+	body := envelope{"error": "validation failed"}
+	if len(v.FieldErrors) > 0 {
+		fieldErrors := make(map[string]string, len(v.FieldErrors))
+		for key, message := range v.FieldErrors {
+			if name, ok := apiFieldNames[key]; ok {
+				key = name
+			}
+			fieldErrors[key] = message
+		}
+		body["field_errors"] = fieldErrors
+	}
+	if len(v.NonFieldErrors) > 0 {
+		body["non_field_errors"] = v.NonFieldErrors
+	}
+
+	app.writeJSON(w, r, http.StatusUnprocessableEntity, body)
+}
+
+// JSON counterpart of snippetError
+func (app *application) apiSnippetError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.apiError(w, r, http.StatusNotFound, "snippet not found")
+	case errors.Is(err, errNotOwner):
+		app.apiError(w, r, http.StatusForbidden, "snippet belongs to another user")
+	case errors.Is(err, errSnippetLocked):
+		app.apiError(w, r, http.StatusForbidden, "snippet is password protected")
+	default:
+		app.apiServerError(w, r, err)
+	}
+}
+
+// Build the listing filter from the query string parameters after, before,
+// size, sort and expired. Invalid values are reported to v.
+func readSnippetFilter(qs url.Values, v *validator.Validator) models.SnippetFilter {
+	filter := models.SnippetFilter{
+		After:          readInt(qs, "after", 0, v),
+		Before:         readInt(qs, "before", 0, v),
+		PageSize:       readInt(qs, "size", models.DefaultPageSize, v),
+		Sort:           qs.Get("sort"),
+		IncludeExpired: qs.Get("expired") == "1",
+	}
+
+	if filter.Sort == "" {
+		filter.Sort = models.SortNewest
+	}
+
+	v.CheckField(
+		filter.After >= 0 && filter.Before >= 0,
+		"cursor",
+		"Cursors must be positive",
+	)
+	v.CheckField(
+		filter.After == 0 || filter.Before == 0,
+		"cursor",
+		"Only one of after and before can be given",
+	)
+	v.CheckField(
+		filter.PageSize >= 1 && filter.PageSize <= models.MaxPageSize,
+		"size",
+		fmt.Sprintf("This field must be between 1 and %d", models.MaxPageSize),
+	)
+	v.CheckField(
+		validator.PermittedValue(filter.Sort, models.SortNewest, models.SortOldest),
+		"sort",
+		fmt.Sprintf("This field must be equal %s or %s", models.SortNewest, models.SortOldest),
+	)
+
+	return filter
+}
+
+// Return the integer value of a query string parameter, or defaultValue if
+// it's missing
+func readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddFieldError(key, "This field must be an integer")
+		return defaultValue
+	}
+	return i
+}
+
+// Return the URL of the listing page found at cursor (after or before),
+// keeping the other query string parameters
+func pageURL(path string, qs url.Values, key string, cursor int) string {
+	if cursor == 0 {
+		return ""
+	}
+
+	params := url.Values{}
+	for k, values := range qs {
+		params[k] = values
+	}
+	params.Del("after")
+	params.Del("before")
+	params.Set(key, strconv.Itoa(cursor))
+
+	return path + "?" + params.Encode()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+	"snippetbox.flaviogalon.github.io/internal/models"
+)
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippet  *models.Snippet
+		expected string
+	}{
+		{
+			name:     "Plain text",
+			snippet:  &models.Snippet{ID: 1, Title: "An old silent pond", Format: models.FormatPlain},
+			expected: "an-old-silent-pond.txt",
+		},
+		{
+			name:     "Language",
+			snippet:  &models.Snippet{ID: 1, Title: "main.go: Hello, World!", Language: "go", Format: models.FormatPlain},
+			expected: "main-go-hello-world.go",
+		},
+		{
+			name:     "Markdown",
+			snippet:  &models.Snippet{ID: 1, Title: "Runbook", Language: "go", Format: models.FormatMarkdown},
+			expected: "runbook.md",
+		},
+		{
+			name:     "No usable characters",
+			snippet:  &models.Snippet{ID: 7, Title: "日本語", Format: models.FormatPlain},
+			expected: "snippet-7.txt",
+		},
+		{
+			name:     "Long title",
+			snippet:  &models.Snippet{ID: 1, Title: strings.Repeat("ab ", 40), Format: models.FormatPlain},
+			expected: strings.Repeat("ab-", 16) + "ab.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, snippetFilename(tt.snippet), tt.expected)
+		})
+	}
+}
+
+func TestParseLifetime(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{
+			name:     "Minutes",
+			value:    "30m",
+			expected: 30 * time.Minute,
+		},
+		{
+			name:     "Hours",
+			value:    "12h",
+			expected: 12 * time.Hour,
+		},
+		{
+			name:     "Weeks",
+			value:    "2w",
+			expected: 14 * 24 * time.Hour,
+		},
+		{
+			name:     "No unit",
+			value:    "7",
+			expected: 7 * 24 * time.Hour,
+		},
+		{
+			name:    "Unknown unit",
+			value:   "1y",
+			wantErr: true,
+		},
+		{
+			name:    "Negative",
+			value:   "-1d",
+			wantErr: true,
+		},
+		{
+			name:    "Empty",
+			value:   "",
+			wantErr: true,
+		},
+		{
+			name:     "Longest",
+			value:    "3650d",
+			expected: maxLifetime,
+		},
+		{
+			name:    "Too long",
+			value:   "3651d",
+			wantErr: true,
+		},
+		{
+			// Would wrap around to about 28 years
+			name:    "Overflow",
+			value:   "32000w",
+			wantErr: true,
+		},
+		{
+			name:    "Out of int range",
+			value:   "99999999999999999999m",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lifetime, err := parseLifetime(tt.value)
+
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, lifetime, tt.expected)
+		})
+	}
+}
+
+func TestFormatLifetime(t *testing.T) {
+	assert.Equal(t, formatLifetime(14*24*time.Hour), "2w")
+	assert.Equal(t, formatLifetime(365*24*time.Hour), "365d")
+	assert.Equal(t, formatLifetime(36*time.Hour), "36h")
+	assert.Equal(t, formatLifetime(90*time.Minute), "90m")
+}
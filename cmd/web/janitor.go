@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Number of snippets deleted by a single query, so that a large backlog
+// doesn't hold locks on the snippets table for long
+const janitorBatchSize = 1000
+
+// Periodically delete the expired and burned snippets until ctx is canceled.
+// Those are already hidden by every query, this only keeps the table from
+// growing forever.
+func (app *application) runJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.purgeExpiredSnippets(ctx)
+		}
+	}
+}
+
+// Delete the expired snippets batch by batch, stopping early on errors or
+// when ctx is canceled
+func (app *application) purgeExpiredSnippets(ctx context.Context) {
+	total := 0
+	for ctx.Err() == nil {
+		deleted, err := app.snippetModel.DeleteExpired(ctx, janitorBatchSize)
+		// Canceled by the shutdown, the next run picks up where this one
+		// stopped
+		if errors.Is(err, context.Canceled) {
+			break
+		}
+		if err != nil {
+			app.logger.ErrorContext(ctx, "janitor failed to purge snippets", "error", err)
+			break
+		}
+
+		total += deleted
+		if deleted < janitorBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		app.logger.InfoContext(ctx, "janitor purged expired snippets", "count", total)
+	}
+}
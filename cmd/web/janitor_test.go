@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+	"snippetbox.flaviogalon.github.io/internal/models/mocks"
+)
+
+// Snippet model deleting the given number of snippets at each call, then
+// failing with err
+type stubPurger struct {
+	*mocks.SnippetModel
+	deleted []int
+	err     error
+	calls   int
+}
+
+func (m *stubPurger) DeleteExpired(ctx context.Context, limit int) (int, error) {
+	m.calls++
+	if m.calls > len(m.deleted) {
+		return 0, m.err
+	}
+	return m.deleted[m.calls-1], nil
+}
+
+func TestRunJanitor(t *testing.T) {
+	app := newTestApplication(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		app.runJanitor(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	// Let a few purges run before asking the janitor to stop
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor didn't stop after its context was canceled")
+	}
+}
+
+func TestPurgeExpiredSnippets(t *testing.T) {
+	tests := []struct {
+		name      string
+		deleted   []int
+		err       error
+		wantCalls int
+		wantLog   string
+		wantError bool
+	}{
+		{
+			name:      "Several batches",
+			deleted:   []int{janitorBatchSize, 3},
+			wantCalls: 2,
+			wantLog:   "count=1003",
+		},
+		{
+			name:      "Nothing expired",
+			deleted:   []int{0},
+			wantCalls: 1,
+		},
+		{
+			name:      "Error",
+			deleted:   []int{janitorBatchSize},
+			err:       errors.New("connection refused"),
+			wantCalls: 2,
+			wantLog:   "count=1000",
+			wantError: true,
+		},
+		{
+			name:      "Canceled",
+			deleted:   []int{janitorBatchSize},
+			err:       context.Canceled,
+			wantCalls: 2,
+			wantLog:   "count=1000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			app := newTestApplication(t)
+			app.logger = slog.New(slog.NewTextHandler(&logs, nil))
+			purger := &stubPurger{SnippetModel: &mocks.SnippetModel{}, deleted: tt.deleted, err: tt.err}
+			app.snippetModel = purger
+
+			app.purgeExpiredSnippets(context.Background())
+
+			assert.Equal(t, purger.calls, tt.wantCalls)
+			assert.Equal(t, strings.Contains(logs.String(), "level=ERROR"), tt.wantError)
+			if tt.wantLog == "" {
+				assert.Equal(t, strings.Contains(logs.String(), "janitor purged"), false)
+			} else {
+				assert.StringContains(t, logs.String(), tt.wantLog)
+			}
+		})
+	}
+}
@@ -1,90 +1,122 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
-	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"time"
+	"os/signal"
+	"sync"
+	"syscall"
 
+	"snippetbox.flaviogalon.github.io/internal/logging"
 	"snippetbox.flaviogalon.github.io/internal/models"
 
-	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 )
 
-type appConfig struct {
-	addr             string
-	staticAssertsDir string
-	debugMode        bool
-}
-
 type application struct {
-	errorLog       *log.Logger
-	infoLog        *log.Logger
+	logger         *slog.Logger
+	accessLog      io.Writer // Destination of the Apache formatted access logs
+	metrics        *metrics  // nil when the metrics endpoint is disabled
+	db             pinger
 	appConfig      *appConfig
 	snippetModel   models.SnippedModelInterface
 	userModel      models.UserModelInterface
+	tokenModel     models.TokenModelInterface
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	unlockLimiter  *unlockLimiter
+	// Tracks the background goroutines to wait for before exiting
+	wg sync.WaitGroup
 }
 
 func main() {
-	// Custom log
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-
-	// Loading env variables from .env file
+	// Loading env variables from the optional .env file
 	err := godotenv.Load()
-	if err != nil {
-		errorLog.Fatal("Error loading .env file")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("Error loading .env file: %s", err)
 	}
-	dbUser := os.Getenv("MYSQL_USER")
-	dbPwd := os.Getenv("MYSQL_PASSWORD")
 
 	// Application configuration
-	var appCfg appConfig
-
-	flag.StringVar(&appCfg.addr, "addr", ":4000", "HTTP network address")
-	flag.StringVar(
-		&appCfg.staticAssertsDir,
-		"static-dir",
-		"./ui/static/",
-		"Path to static assets",
-	)
-	dsn := flag.String(
-		"dsn",
-		fmt.Sprintf("%s:%s@/snippetbox?parseTime=true", dbUser, dbPwd),
-		"MySQL data source name",
-	)
-	debugMode := flag.Bool(
-		"debug",
-		false,
-		"Debug Mode",
-	)
-	flag.Parse()
-
-	appCfg.debugMode = *debugMode
-
-	// Database pool
-	db, err := openDB(*dsn)
+	appCfg, flags, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if appCfg.printConfig {
+		printConfig(os.Stdout, flags)
+		return
+	}
+
+	// Subcommands only need the database settings
+	if flags.NArg() > 0 {
+		err = runCommand(os.Stdout, appCfg, flags.Args())
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	err = appCfg.validate()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%s", err)
+	}
+
+	// Structured logger
+	logger, err := logging.New(os.Stdout, appCfg.logFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = run(appCfg, logger)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// Open the store, then serve the application until SIGINT or SIGTERM. The
+// deferred cleanups run before main exits, whether it fails or not.
+func run(appCfg *appConfig, logger *slog.Logger) error {
+	// Models and session store
+	store, err := openStorage(appCfg, logger)
 	if err != nil {
-		errorLog.Fatal(err)
+		return err
+	}
+	defer store.Close()
+
+	// Schema migrations, the memory store has no schema
+	if appCfg.autoMigrate && store.db != nil {
+		migrationModel := &models.MigrationModel{DB: store.db.DB, Dialect: store.db.dialect}
+		applied, err := migrationModel.Up(context.Background())
+		for _, migration := range applied {
+			logger.Info("applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			return err
+		}
 	}
-	defer db.Close()
 
 	// Template cache
 	templateCache, err := newTemplateCache()
 	if err != nil {
-		errorLog.Fatal(err)
+		return err
 	}
 
 	// Form Decoder
@@ -92,20 +124,54 @@ func main() {
 
 	// Session Manager
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Store = store.sessions
+	sessionManager.Lifetime = appCfg.sessionLifetime
 	sessionManager.Cookie.Secure = true
 
 	// Application instance
 	app := &application{
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		appConfig:      &appCfg,
-		snippetModel:   &models.SnippetModel{DB: db},
-		userModel:      &models.UserModel{DB: db},
+		logger:         logger,
+		accessLog:      os.Stdout,
+		db:             store.pinger,
+		appConfig:      appCfg,
+		snippetModel:   store.snippetModel,
+		userModel:      store.userModel,
+		tokenModel:     store.tokenModel,
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		unlockLimiter:  newUnlockLimiter(),
+	}
+	if appCfg.metricsAddr != "" {
+		var db *sql.DB
+		if store.db != nil {
+			db = store.db.DB
+		}
+		app.metrics = newMetrics(db)
+	}
+
+	// Canceled by SIGINT or SIGTERM to start the shutdown, or on return. The
+	// background goroutines are waited for before the store is closed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer app.wg.Wait()
+	defer stop()
+
+	// Metrics are served apart from the application so they aren't public
+	if app.metrics != nil {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			app.serveMetrics(ctx, appCfg.metricsAddr)
+		}()
+	}
+
+	// Background purge of the expired snippets
+	if appCfg.janitorInterval > 0 {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			app.runJanitor(ctx, appCfg.janitorInterval)
+		}()
 	}
 
 	// Non-default TLS settings
@@ -116,26 +182,18 @@ func main() {
 	// Web Server
 	server := &http.Server{
 		Addr:         appCfg.addr,
-		ErrorLog:     app.errorLog,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		Handler:      app.routes(),
 		TLSConfig:    tlsConfig,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  appCfg.idleTimeout,
+		ReadTimeout:  appCfg.readTimeout,
+		WriteTimeout: appCfg.writeTimeout,
 	}
 
-	app.infoLog.Printf("Starting server on %s", appCfg.addr)
-	err = server.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	app.errorLog.Fatal(err)
-}
-
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+	ln, err := net.Listen("tcp", appCfg.addr)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if err = db.Ping(); err != nil {
-		return nil, err
-	}
-	return db, nil
+
+	return app.serve(ctx, server, ln, appCfg.shutdownTimeout)
 }
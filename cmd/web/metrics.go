@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus collectors of the application. A nil *metrics is valid and
+// records nothing, which is the case when the metrics endpoint is disabled.
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	snippetsCreated prometheus.Counter
+	logins          *prometheus.CounterVec
+}
+
+// Register the application collectors, along with the Go runtime, process
+// and database pool (when db isn't nil) ones
+func newMetrics(db *sql.DB) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "snippetbox_http_requests_total",
+				Help: "HTTP requests served, by route pattern and status code.",
+			},
+			[]string{"method", "route", "status"},
+		),
+		requestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "snippetbox_http_request_duration_seconds",
+				Help:    "Time taken to serve HTTP requests, by route pattern.",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"method", "route"},
+		),
+		snippetsCreated: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "snippetbox_snippets_created_total",
+				Help: "Snippets created from the web interface or the API.",
+			},
+		),
+		logins: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "snippetbox_logins_total",
+				Help: "Login attempts, by result (success or failure).",
+			},
+			[]string{"result"},
+		),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.snippetsCreated,
+		m.logins,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "snippetbox"))
+	}
+
+	return m
+}
+
+// Count and time the requests served by next. route is the router pattern
+// rather than the request path, to keep the number of series bounded.
+func (m *metrics) instrument(route string, next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		m.requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (m *metrics) snippetCreated() {
+	if m == nil {
+		return
+	}
+	m.snippetsCreated.Inc()
+}
+
+func (m *metrics) login(success bool) {
+	if m == nil {
+		return
+	}
+	if success {
+		m.logins.WithLabelValues("success").Inc()
+	} else {
+		m.logins.WithLabelValues("failure").Inc()
+	}
+}
+
+// Serve /metrics on its own listener until ctx is canceled. It's kept off the
+// public server so that it's only reachable where addr is.
+func (app *application) serveMetrics(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ErrorLog:          slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		server.Shutdown(shutdownCtx)
+	}()
+
+	app.logger.Info("starting metrics server", "addr", addr)
+	err := server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		app.logger.Error("metrics server failed", "error", err)
+	}
+}
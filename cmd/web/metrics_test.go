@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	app.metrics = newMetrics(nil)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.get(t, "/snippet/view/1")
+	ts.get(t, "/snippet/view/2")
+	ts.get(t, "/snippet/view/3")
+	ts.get(t, "/no/such/page")
+
+	requests := app.metrics.requests
+	// Requests are labelled with the route pattern, not their path
+	assert.Equal(t, testutil.ToFloat64(requests.WithLabelValues(http.MethodGet, "/snippet/view/:id", "200")), 1)
+	assert.Equal(t, testutil.ToFloat64(requests.WithLabelValues(http.MethodGet, "/snippet/view/:id", "404")), 2)
+	assert.Equal(t, testutil.ToFloat64(requests.WithLabelValues(http.MethodGet, "unmatched", "404")), 1)
+
+	ts.login(t)
+
+	assert.Equal(t, testutil.ToFloat64(app.metrics.logins.WithLabelValues("success")), 1)
+	assert.Equal(t, testutil.ToFloat64(app.metrics.logins.WithLabelValues("failure")), 0)
+}
@@ -2,10 +2,21 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/justinas/nosurf"
+
+	"snippetbox.flaviogalon.github.io/internal/logging"
+	"snippetbox.flaviogalon.github.io/internal/models"
 )
 
 func secureHeaders(next http.Handler) http.Handler {
@@ -28,28 +39,110 @@ func noSurf(next http.Handler) http.Handler {
 		Path:     "/",
 		Secure:   true,
 	})
+	// Token clients don't rely on cookies so they can't be victims of CSRF.
+	// Browsers can't attach an Authorization header cross-origin without a
+	// CORS preflight, and invalid tokens are rejected by authenticateToken.
+	csrfHandler.ExemptFunc(hasBearerToken)
 	return csrfHandler
 }
 
+// Accepted format of the request IDs set by a proxy in front of the server
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Tag the request with an ID, sent back in the X-Request-ID header and added
+// to the log lines written while serving it. The ID set by a proxy in front
+// of the server is kept, so that its logs can be matched with ours.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := logging.WithRequestID(r.Context(), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Return a random request ID of 32 hexadecimal characters
+func newRequestID() string {
+	randomBytes := make([]byte, 16)
+	// Never fails, see crypto/rand.Read
+	rand.Read(randomBytes)
+	return hex.EncodeToString(randomBytes)
+}
+
+// Formats of the access log lines. Structured lines go through the
+// application logger, the others are the Apache formats written as is.
+const (
+	accessLogStructured = "structured"
+	accessLogCommon     = "common"
+	accessLogCombined   = "combined"
+)
+
+// Write an access log line once the request has been served
 func (app *application) logRequests(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf(
-			"%s - %s %s %s",
-			r.RemoteAddr,
-			r.Proto,
-			r.Method,
-			r.URL.RequestURI(),
-		)
-		next.ServeHTTP(w, r)
+		start := time.Now()
+		rec := newResponseRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		switch app.appConfig.accessLogFormat {
+		case accessLogCommon, accessLogCombined:
+			fmt.Fprintln(app.accessLog, apacheLogLine(r, rec, start, app.appConfig.accessLogFormat))
+		default:
+			app.logger.InfoContext(
+				r.Context(),
+				"handled request",
+				"ip", r.RemoteAddr,
+				"proto", r.Proto,
+				"method", r.Method,
+				"uri", r.URL.RequestURI(),
+				"status", rec.status,
+				"bytes", rec.bytes,
+				"duration", time.Since(start),
+			)
+		}
 	})
 }
 
+// Format a request in the Common or Combined Log Format of Apache
+func apacheLogLine(r *http.Request, rec *responseRecorder, start time.Time, format string) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	size := "-"
+	if rec.bytes > 0 {
+		size = strconv.Itoa(rec.bytes)
+	}
+
+	line := fmt.Sprintf(
+		`%s - - [%s] "%s %s %s" %d %s`,
+		host,
+		start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method,
+		r.URL.RequestURI(),
+		r.Proto,
+		rec.status,
+		size,
+	)
+	if format == accessLogCombined {
+		line += fmt.Sprintf(" %q %q", r.Referer(), r.UserAgent())
+	}
+	return line
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
 				w.Header().Set("Connection", "close")
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -69,6 +162,20 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// API counterpart of requireAuthentication: answers with a JSON 401 instead
+// of redirecting to the login page
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.apiError(w, r, http.StatusUnauthorized, "authentication required")
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := app.sessionManager.GetInt(r.Context(), TOKEN_AUTHENTICATED_USER_ID)
@@ -78,17 +185,57 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		exists, err := app.userModel.Exists(id)
+		exists, err := app.userModel.Exists(r.Context(), id)
 		if err != nil {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 			return
 		}
 
-		// If the user exists in DB we can add the authenticated key to context
+		// If the user exists in DB we can add the authenticated keys to context
 		if exists {
-			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
-			r = r.WithContext(ctx)
+			r = withAuthenticatedUser(r, id)
 		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Authenticate requests carrying an "Authorization: Bearer <token>" header
+// with a personal API token. Requests without the header are left untouched
+// so it can be chained after the session based authenticate.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		if !hasBearerToken(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		plaintext := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+
+		id, err := app.tokenModel.Authenticate(r.Context(), plaintext)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				app.apiError(w, r, http.StatusUnauthorized, "invalid or revoked API token")
+			} else {
+				app.apiServerError(w, r, err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, withAuthenticatedUser(r, id))
+	})
+}
+
+// Return true if the request carries an API token
+func hasBearerToken(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// Return a copy of the request whose context marks the user as authenticated
+func withAuthenticatedUser(r *http.Request, id int) *http.Request {
+	ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+	ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+	return r.WithContext(ctx)
+}
@@ -5,9 +5,11 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"snippetbox.flaviogalon.github.io/internal/assert"
+	"snippetbox.flaviogalon.github.io/internal/logging"
 )
 
 func TestSecureHeaders(t *testing.T) {
@@ -52,3 +54,103 @@ func TestSecureHeaders(t *testing.T) {
 
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		headerID string
+		wantID   string
+	}{
+		{
+			name:     "Generated",
+			headerID: "",
+		},
+		{
+			name:     "Set by a proxy",
+			headerID: "7f3c2a9e-proxy.1",
+			wantID:   "7f3c2a9e-proxy.1",
+		},
+		{
+			name:     "Invalid proxy ID",
+			headerID: "<script>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responseRecorder := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.headerID != "" {
+				r.Header.Set("X-Request-ID", tt.headerID)
+			}
+
+			var contextID string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contextID = logging.RequestID(r.Context())
+			})
+
+			requestID(next).ServeHTTP(responseRecorder, r)
+
+			headerID := responseRecorder.Result().Header.Get("X-Request-ID")
+			assert.Equal(t, headerID, contextID)
+
+			if tt.wantID != "" {
+				assert.Equal(t, headerID, tt.wantID)
+			} else {
+				assert.Equal(t, len(headerID), 32)
+			}
+		})
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})
+
+	tests := []struct {
+		name     string
+		format   string
+		wantLine string
+	}{
+		{
+			name:     "Common",
+			format:   accessLogCommon,
+			wantLine: `"GET /snippets?size=5 HTTP/1.1" 418 15` + "\n",
+		},
+		{
+			name:     "Combined",
+			format:   accessLogCombined,
+			wantLine: `"GET /snippets?size=5 HTTP/1.1" 418 15 "https://example.com/" "curl/8.0"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var accessLog bytes.Buffer
+
+			app := newTestApplication(t)
+			app.appConfig.accessLogFormat = tt.format
+			app.accessLog = &accessLog
+
+			r, err := http.NewRequest(http.MethodGet, "/snippets?size=5", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.RemoteAddr = "192.0.2.1:1234"
+			r.Header.Set("Referer", "https://example.com/")
+			r.Header.Set("User-Agent", "curl/8.0")
+
+			app.logRequests(next).ServeHTTP(httptest.NewRecorder(), r)
+
+			line := accessLog.String()
+			assert.StringContains(t, line, "192.0.2.1 - - [")
+			assert.Equal(t, strings.HasSuffix(line, tt.wantLine), true)
+		})
+	}
+}
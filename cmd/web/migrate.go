@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"snippetbox.flaviogalon.github.io/internal/models"
+)
+
+const commandUsage = "[migrate up|down|status]"
+
+var errCommandUsage = errors.New("usage: snippetbox [flags] " + commandUsage)
+
+// Run the subcommand given after the flags, writing its output to w
+func runCommand(w io.Writer, cfg *appConfig, args []string) error {
+	if args[0] != "migrate" || len(args) != 2 {
+		return errCommandUsage
+	}
+
+	if cfg.store == storeMemory {
+		return errors.New("the memory store has no schema to migrate")
+	}
+
+	dialect, err := models.ParseDialect(cfg.store)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(dialect, cfg.dsn, cfg.pool, slog.Default())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return runMigrate(context.Background(), w, &models.MigrationModel{DB: db.DB, Dialect: dialect}, args[1])
+}
+
+// Apply the pending migrations, revert the latest one or list them
+func runMigrate(ctx context.Context, w io.Writer, m *models.MigrationModel, action string) error {
+	switch action {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(w, "applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(w, "no pending migration")
+		}
+
+	case "down":
+		migration, err := m.Down(ctx)
+		if errors.Is(err, models.ErrNoRecord) {
+			fmt.Fprintln(w, "no migration to revert")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "reverted %04d_%s\n", migration.Version, migration.Name)
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, status := range statuses {
+			applied := "pending"
+			if !status.Applied.IsZero() {
+				applied = status.Applied.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return tw.Flush()
+
+	default:
+		return errCommandUsage
+	}
+
+	return nil
+}
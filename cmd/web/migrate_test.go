@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+)
+
+func TestRunCommandMigrate(t *testing.T) {
+	cfg := &appConfig{
+		store: "sqlite",
+		dsn:   "file:" + filepath.Join(t.TempDir(), "snippetbox.db"),
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantOut string
+		wantErr error
+	}{
+		{
+			name:    "Nothing to revert",
+			args:    []string{"migrate", "down"},
+			wantOut: "no migration to revert\n",
+		},
+		{
+			name:    "Pending status",
+			args:    []string{"migrate", "status"},
+			wantOut: "0011     add_snippet_expiry_index      pending\n",
+		},
+		{
+			name:    "Up",
+			args:    []string{"migrate", "up"},
+			wantOut: "applied 0001_create_tables\napplied 0002_add_snippet_authors\n",
+		},
+		{
+			name:    "Up to date",
+			args:    []string{"migrate", "up"},
+			wantOut: "no pending migration\n",
+		},
+		{
+			name:    "Down",
+			args:    []string{"migrate", "down"},
+			wantOut: "reverted 0011_add_snippet_expiry_index\n",
+		},
+		{
+			name:    "Up again",
+			args:    []string{"migrate", "up"},
+			wantOut: "applied 0011_add_snippet_expiry_index\n",
+		},
+		{
+			name:    "Unknown action",
+			args:    []string{"migrate", "sideways"},
+			wantErr: errCommandUsage,
+		},
+		{
+			name:    "Unknown command",
+			args:    []string{"serve"},
+			wantErr: errCommandUsage,
+		},
+	}
+
+	// The cases run in order against the same database
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runCommand(&out, cfg, tt.args)
+
+			assert.Equal(t, err, tt.wantErr)
+			assert.StringContains(t, out.String(), tt.wantOut)
+		})
+	}
+}
+
+func TestRunCommandMemoryStore(t *testing.T) {
+	err := runCommand(io.Discard, &appConfig{store: storeMemory}, []string{"migrate", "up"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	assert.StringContains(t, err.Error(), "no schema to migrate")
+}
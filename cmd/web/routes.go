@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
@@ -12,22 +13,41 @@ import (
 func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
-	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	router.NotFound = app.metrics.instrument("unmatched", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiNotFound(w, r)
+			return
+		}
 		app.notFound(w)
-	})
+	}))
+
+	// Register a route, instrumented under its pattern
+	handle := func(method, path string, handler http.Handler) {
+		router.Handler(method, path, app.metrics.instrument(path, handler))
+	}
 
 	// Embedded File Server
 	fileServer := http.FileServer(http.FS(ui.Files))
 
-	router.Handler(
+	handle(
 		http.MethodGet,
 		"/static/*filepath",
 		fileServer,
 	)
-	router.HandlerFunc(
+	handle(
 		http.MethodGet,
 		"/ping",
-		ping,
+		http.HandlerFunc(ping),
+	)
+	handle(
+		http.MethodGet,
+		"/healthz",
+		http.HandlerFunc(app.healthz),
+	)
+	handle(
+		http.MethodGet,
+		"/readyz",
+		http.HandlerFunc(app.readyz),
 	)
 
 	// Unprotected application routes
@@ -35,36 +55,62 @@ func (app *application) routes() http.Handler {
 		app.sessionManager.LoadAndSave,
 		noSurf,
 		app.authenticate,
+		app.authenticateToken,
 	)
 
-	router.Handler(http.MethodGet, "/", dynamicMid.ThenFunc(app.home))
-	router.Handler(
+	handle(http.MethodGet, "/", dynamicMid.ThenFunc(app.home))
+	handle(
 		http.MethodGet,
 		"/about",
 		dynamicMid.ThenFunc(app.about),
 	)
 	// Snippet
-	router.Handler(
+	handle(
+		http.MethodGet,
+		"/snippets",
+		dynamicMid.ThenFunc(app.snippetList),
+	)
+	handle(
+		http.MethodGet,
+		"/search",
+		dynamicMid.ThenFunc(app.search),
+	)
+	handle(
 		http.MethodGet,
 		"/snippet/view/:id",
 		dynamicMid.ThenFunc(app.snippetView),
 	)
-	router.Handler(
+	handle(
+		http.MethodPost,
+		"/snippet/unlock/:id",
+		dynamicMid.ThenFunc(app.snippetUnlockPost),
+	)
+	handle(
+		http.MethodGet,
+		"/snippet/raw/:id",
+		dynamicMid.ThenFunc(app.snippetRaw),
+	)
+	handle(
+		http.MethodGet,
+		"/snippet/download/:id",
+		dynamicMid.ThenFunc(app.snippetDownload),
+	)
+	handle(
 		http.MethodGet,
 		"/user/signup",
 		dynamicMid.ThenFunc(app.userSignup),
 	)
-	router.Handler(
+	handle(
 		http.MethodPost,
 		"/user/signup",
 		dynamicMid.ThenFunc(app.userSignupPost),
 	)
-	router.Handler(
+	handle(
 		http.MethodGet,
 		"/user/login",
 		dynamicMid.ThenFunc(app.userLogin),
 	)
-	router.Handler(
+	handle(
 		http.MethodPost,
 		"/user/login",
 		dynamicMid.ThenFunc(app.userLoginPost),
@@ -72,40 +118,107 @@ func (app *application) routes() http.Handler {
 
 	// Protected application routes (copying all mid from unprotected)
 	protected := dynamicMid.Append(app.requireAuthentication)
-	router.Handler(
+	handle(
 		http.MethodGet,
 		"/snippet/create",
 		protected.ThenFunc(app.snippetCreate),
 	)
-	router.Handler(
+	handle(
 		http.MethodPost,
 		"/snippet/create",
 		protected.ThenFunc(app.snippetCreatePost),
 	)
-	router.Handler(
+	handle(
+		http.MethodGet,
+		"/snippet/edit/:id",
+		protected.ThenFunc(app.snippetEdit),
+	)
+	handle(
+		http.MethodPost,
+		"/snippet/edit/:id",
+		protected.ThenFunc(app.snippetEditPost),
+	)
+	handle(
+		http.MethodPost,
+		"/snippet/delete/:id",
+		protected.ThenFunc(app.snippetDeletePost),
+	)
+	handle(
 		http.MethodPost,
 		"/user/logout",
 		protected.ThenFunc(app.userLogoutPost),
 	)
-	router.Handler(
+	handle(
 		http.MethodGet,
 		"/account/view",
 		protected.ThenFunc(app.accountView),
 	)
-	router.Handler(
+	handle(
+		http.MethodPost,
+		"/account/tokens/create",
+		protected.ThenFunc(app.accountTokenCreatePost),
+	)
+	handle(
+		http.MethodPost,
+		"/account/tokens/revoke/:id",
+		protected.ThenFunc(app.accountTokenRevokePost),
+	)
+	handle(
 		http.MethodGet,
 		"/account/password/update",
 		protected.ThenFunc(app.accountPasswordUpdate),
 	)
-	router.Handler(
+	handle(
 		http.MethodPost,
 		"/account/password/update",
 		protected.ThenFunc(app.accountPasswordUpdatePost),
 	)
 
+	// JSON API routes. There's no CSRF middleware here: the write endpoints
+	// only accept application/json bodies, which browsers can't send
+	// cross-origin without a CORS preflight.
+	apiMid := alice.New(
+		app.sessionManager.LoadAndSave,
+		app.authenticate,
+		app.authenticateToken,
+	)
+	handle(
+		http.MethodGet,
+		"/api/v1/snippets",
+		apiMid.ThenFunc(app.apiSnippetList),
+	)
+	handle(
+		http.MethodGet,
+		"/api/v1/snippets/:id",
+		apiMid.ThenFunc(app.apiSnippetGet),
+	)
+
+	apiProtected := apiMid.Append(app.requireAPIAuthentication)
+	handle(
+		http.MethodPost,
+		"/api/v1/snippets",
+		apiProtected.ThenFunc(app.apiSnippetCreate),
+	)
+	handle(
+		http.MethodPut,
+		"/api/v1/snippets/:id",
+		apiProtected.ThenFunc(app.apiSnippetUpdate),
+	)
+	handle(
+		http.MethodDelete,
+		"/api/v1/snippets/:id",
+		apiProtected.ThenFunc(app.apiSnippetDelete),
+	)
+	handle(
+		http.MethodGet,
+		"/api/v1/whoami",
+		apiProtected.ThenFunc(app.apiWhoami),
+	)
+
 	standardMiddleware := alice.New(
-		app.recoverPanic,
+		requestID,
 		app.logRequests,
+		app.recoverPanic,
 		secureHeaders,
 	)
 
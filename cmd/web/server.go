@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Serve HTTPS on ln until ctx is canceled, then stop accepting connections and
+// give the in-flight requests up to shutdownTimeout to complete. Returns once
+// the background goroutines are done as well, even when the shutdown timed
+// out, so that the caller can release the resources they use.
+func (app *application) serve(ctx context.Context, server *http.Server, ln net.Listener, shutdownTimeout time.Duration) error {
+	shutdownErr := make(chan error, 1)
+
+	go func() {
+		<-ctx.Done()
+		app.logger.Info("shutting down server")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		shutdownErr <- server.Shutdown(shutdownCtx)
+	}()
+
+	app.logger.Info("starting server", "addr", ln.Addr().String())
+	err := server.ServeTLS(ln, app.appConfig.tlsCert, app.appConfig.tlsKey)
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownErr
+
+	app.wg.Wait()
+
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("stopped server")
+	return nil
+}
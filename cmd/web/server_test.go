@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+)
+
+func TestServe(t *testing.T) {
+	tests := []struct {
+		name            string
+		shutdownTimeout time.Duration
+		// How long the in-flight request keeps running after the shutdown
+		requestDelay time.Duration
+		wantErr      error
+	}{
+		{
+			name:            "Graceful",
+			shutdownTimeout: 5 * time.Second,
+			requestDelay:    100 * time.Millisecond,
+		},
+		{
+			name:            "Deadline exceeded",
+			shutdownTimeout: 50 * time.Millisecond,
+			requestDelay:    500 * time.Millisecond,
+			wantErr:         context.DeadlineExceeded,
+		},
+	}
+
+	// Certificate of a throwaway test server, as the configured files don't
+	// exist in the tests
+	certServer := httptest.NewTLSServer(nil)
+	client := certServer.Client()
+	certificates := certServer.TLS.Certificates
+	certServer.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			started := make(chan struct{})
+			var requestDone atomic.Bool
+			server := &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					close(started)
+					time.Sleep(tt.requestDelay)
+					io.WriteString(w, "OK")
+					requestDone.Store(true)
+				}),
+				TLSConfig: &tls.Config{Certificates: certificates},
+			}
+
+			// A background goroutine still busy when the shutdown starts
+			var backgroundDone atomic.Bool
+			app.wg.Add(1)
+			go func() {
+				defer app.wg.Done()
+				<-ctx.Done()
+				time.Sleep(tt.requestDelay)
+				backgroundDone.Store(true)
+			}()
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NilError(t, err)
+
+			served := make(chan error, 1)
+			go func() {
+				served <- app.serve(ctx, server, ln, tt.shutdownTimeout)
+			}()
+
+			codes := make(chan int, 1)
+			go func() {
+				rs, err := client.Get("https://" + ln.Addr().String())
+				if err != nil {
+					codes <- 0
+					return
+				}
+				rs.Body.Close()
+				codes <- rs.StatusCode
+			}()
+
+			<-started
+			cancel()
+
+			err = <-served
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+			assert.Equal(t, backgroundDone.Load(), true)
+			// The in-flight request completed before serve returned
+			if tt.wantErr == nil {
+				assert.Equal(t, requestDone.Load(), true)
+				assert.Equal(t, <-codes, http.StatusOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/pgxstore"
+	"github.com/alexedwards/scs/sqlite3store"
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+
+	"snippetbox.flaviogalon.github.io/internal/models"
+	"snippetbox.flaviogalon.github.io/internal/models/memory"
+)
+
+// Store keeping the data and the sessions in memory, lost on exit. It needs
+// no database, which makes it handy for development.
+const storeMemory = "memory"
+
+// Models and session store backed by the configured store
+type storage struct {
+	snippetModel models.SnippedModelInterface
+	userModel    models.UserModelInterface
+	tokenModel   models.TokenModelInterface
+	sessions     sessionStore
+	// Checked by the readiness probe
+	pinger pinger
+	// nil for the memory store
+	db *database
+}
+
+// Open the configured store and build the models on top of it. Failed
+// attempts to reach the database are logged to logger.
+func openStorage(cfg *appConfig, logger *slog.Logger) (*storage, error) {
+	if cfg.store == storeMemory {
+		db := memory.New()
+		return &storage{
+			snippetModel: &memory.SnippetModel{DB: db, BcryptCost: cfg.bcryptCost},
+			userModel:    &memory.UserModel{DB: db, BcryptCost: cfg.bcryptCost},
+			tokenModel:   &memory.TokenModel{DB: db},
+			sessions:     memstore.New(),
+			pinger:       db,
+		}, nil
+	}
+
+	dialect, err := models.ParseDialect(cfg.store)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openDB(dialect, cfg.dsn, cfg.pool, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage{
+		snippetModel: &models.SnippetModel{
+			DB:           db.DB,
+			Dialect:      dialect,
+			BcryptCost:   cfg.bcryptCost,
+			QueryTimeout: cfg.queryTimeout,
+		},
+		userModel: &models.UserModel{
+			DB:           db.DB,
+			Dialect:      dialect,
+			BcryptCost:   cfg.bcryptCost,
+			QueryTimeout: cfg.queryTimeout,
+		},
+		tokenModel: &models.TokenModel{
+			DB:           db.DB,
+			Dialect:      dialect,
+			QueryTimeout: cfg.queryTimeout,
+		},
+		sessions: db.sessions,
+		pinger:   db,
+		db:       db,
+	}, nil
+}
+
+// Stop the session cleanup and close the database pool
+func (s *storage) Close() error {
+	if s.db == nil {
+		s.sessions.StopCleanup()
+		return nil
+	}
+	return s.db.Close()
+}
+
+// A session store running a background cleanup of the expired sessions
+type sessionStore interface {
+	scs.Store
+	StopCleanup()
+}
+
+// Database pool along with the session store keeping the sessions in it
+type database struct {
+	*sql.DB
+	dialect  models.Dialect
+	sessions sessionStore
+	// Pool shared by DB and the sessions, PostgreSQL only
+	pool *pgxpool.Pool
+}
+
+// Settings of the database connection pool
+type poolConfig struct {
+	// 0 for no limit
+	maxOpenConns int
+	// 0 to close the connections as soon as they are released
+	maxIdleConns int
+	// 0 to reuse the connections forever
+	connMaxLifetime time.Duration
+	connMaxIdleTime time.Duration
+	// How long to wait for the database to come up, 0 to try once
+	connectTimeout time.Duration
+}
+
+// Delay before the second attempt to reach the database, doubled after each
+// failure up to maxConnectDelay. Also bounds every attempt.
+const (
+	connectDelay    = 250 * time.Millisecond
+	maxConnectDelay = 5 * time.Second
+)
+
+// Open a database pool of the given dialect and wait for it to be reachable
+func openDB(dialect models.Dialect, dsn string, cfg poolConfig, logger *slog.Logger) (*database, error) {
+	db := &database{dialect: dialect}
+
+	switch dialect {
+	case models.Postgres:
+		// The PostgreSQL session store needs a pgx pool, which database/sql
+		// can run on. The pgx pool manages the connections then, and has no
+		// idle connections limit.
+		poolCfg, err := pgxpool.ParseConfig(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.maxOpenConns > 0 {
+			poolCfg.MaxConns = int32(cfg.maxOpenConns)
+		}
+		if cfg.connMaxLifetime > 0 {
+			poolCfg.MaxConnLifetime = cfg.connMaxLifetime
+		}
+		if cfg.connMaxIdleTime > 0 {
+			poolCfg.MaxConnIdleTime = cfg.connMaxIdleTime
+		}
+
+		pool, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
+		if err != nil {
+			return nil, err
+		}
+		db.pool = pool
+		db.DB = stdlib.OpenDBFromPool(pool)
+	case models.SQLite:
+		conn, err := sql.Open(dialect.DriverName(), sqliteDSN(dsn))
+		if err != nil {
+			return nil, err
+		}
+		db.DB = conn
+	default:
+		conn, err := sql.Open(dialect.DriverName(), dsn)
+		if err != nil {
+			return nil, err
+		}
+		db.DB = conn
+	}
+
+	if db.pool == nil {
+		db.SetMaxOpenConns(cfg.maxOpenConns)
+		db.SetMaxIdleConns(cfg.maxIdleConns)
+		db.SetConnMaxLifetime(cfg.connMaxLifetime)
+		db.SetConnMaxIdleTime(cfg.connMaxIdleTime)
+	}
+
+	err := waitForDB(context.Background(), db, cfg.connectTimeout, logger)
+	if err != nil {
+		db.closePool()
+		return nil, err
+	}
+
+	switch dialect {
+	case models.Postgres:
+		db.sessions = pgxstore.New(db.pool)
+	case models.SQLite:
+		db.sessions = sqlite3store.New(db.DB)
+	default:
+		db.sessions = mysqlstore.New(db.DB)
+	}
+
+	return db, nil
+}
+
+// Clock of waitForDB, replaced by the tests to run without waiting
+var (
+	timeNow   = time.Now
+	timeAfter = time.After
+)
+
+// Ping the database until it answers or maxWait elapses, waiting longer
+// after each failed attempt. Gives the database time to start when both are
+// started together, e.g. by docker compose.
+func waitForDB(ctx context.Context, db pinger, maxWait time.Duration, logger *slog.Logger) error {
+	deadline := timeNow().Add(maxWait)
+	delay := connectDelay
+
+	for attempt := 1; ; attempt++ {
+		// Attempts get the time left, but at least connectDelay for the last
+		// one made at the deadline. When maxWait is 0, the only attempt gets
+		// maxConnectDelay.
+		timeout := maxConnectDelay
+		if maxWait > 0 {
+			timeout = min(timeout, max(deadline.Sub(timeNow()), connectDelay))
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		remaining := deadline.Sub(timeNow())
+		if remaining <= 0 {
+			return fmt.Errorf("database unreachable after %d attempt(s): %w", attempt, err)
+		}
+		delay = min(delay, remaining)
+		logger.Warn("database unreachable, retrying", "attempt", attempt, "delay", delay, "error", err)
+
+		select {
+		case <-timeAfter(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay = min(2*delay, maxConnectDelay)
+	}
+}
+
+// Stop the session cleanup and close the pool
+func (db *database) Close() error {
+	if db.sessions != nil {
+		db.sessions.StopCleanup()
+	}
+	return db.closePool()
+}
+
+func (db *database) closePool() error {
+	err := db.DB.Close()
+	if db.pool != nil {
+		db.pool.Close()
+	}
+	return err
+}
+
+// Timestamps must be written in a format the SQLite date functions, used by
+// the session store, understand
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "_time_format=") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&_time_format=sqlite"
+	}
+	return dsn + "?_time_format=sqlite"
+}
+
+// Data source name used when none is configured
+func defaultDSN(dialect models.Dialect) string {
+	switch dialect {
+	case models.SQLite:
+		return "file:snippetbox.db?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	case models.Postgres:
+		// Connection settings are read from the PG* environment variables
+		return "postgres:///snippetbox"
+	default:
+		return fmt.Sprintf(
+			"%s:%s@/snippetbox?parseTime=true",
+			os.Getenv("MYSQL_USER"),
+			os.Getenv("MYSQL_PASSWORD"),
+		)
+	}
+}
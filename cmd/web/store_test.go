@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+)
+
+// Pinger failing a number of times before answering, recording the timeout
+// of each attempt
+type flakyPinger struct {
+	failures int
+	timeouts []time.Duration
+}
+
+func (p *flakyPinger) PingContext(ctx context.Context) error {
+	deadline, _ := ctx.Deadline()
+	p.timeouts = append(p.timeouts, time.Until(deadline).Round(10*time.Millisecond))
+	if len(p.timeouts) <= p.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// Clock moving forward by the delays waited for instead of sleeping
+type fakeClock struct {
+	now    time.Time
+	delays []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.delays = append(c.delays, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestWaitForDB(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name         string
+		failures     int
+		maxWait      time.Duration
+		wantTimeouts []time.Duration
+		wantDelays   []time.Duration
+		wantErr      string
+	}{
+		{
+			name:         "Up",
+			maxWait:      time.Second,
+			wantTimeouts: []time.Duration{time.Second},
+		},
+		{
+			name:         "Comes up",
+			failures:     2,
+			maxWait:      time.Second,
+			wantTimeouts: []time.Duration{time.Second, 750 * time.Millisecond, 250 * time.Millisecond},
+			wantDelays:   []time.Duration{250 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{
+			name:         "Single attempt",
+			failures:     1,
+			wantTimeouts: []time.Duration{maxConnectDelay},
+			wantErr:      "database unreachable after 1 attempt(s): connection refused",
+		},
+		{
+			// Waits 250ms then 50ms, the time left
+			name:         "Never comes up",
+			failures:     10,
+			maxWait:      300 * time.Millisecond,
+			wantTimeouts: []time.Duration{300 * time.Millisecond, 250 * time.Millisecond, 250 * time.Millisecond},
+			wantDelays:   []time.Duration{250 * time.Millisecond, 50 * time.Millisecond},
+			wantErr:      "database unreachable after 3 attempt(s): connection refused",
+		},
+		{
+			name:     "Long wait",
+			failures: 10,
+			maxWait:  10 * time.Second,
+			wantTimeouts: []time.Duration{
+				5 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second,
+				2250 * time.Millisecond, 250 * time.Millisecond,
+			},
+			wantDelays: []time.Duration{
+				250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second,
+				2250 * time.Millisecond,
+			},
+			wantErr: "database unreachable after 7 attempt(s): connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Now()}
+			timeNow, timeAfter = clock.Now, clock.After
+			t.Cleanup(func() { timeNow, timeAfter = time.Now, time.After })
+
+			db := &flakyPinger{failures: tt.failures}
+
+			err := waitForDB(context.Background(), db, tt.maxWait, logger)
+
+			assert.Equal(t, slices.Equal(db.timeouts, tt.wantTimeouts), true)
+			assert.Equal(t, slices.Equal(clock.delays, tt.wantDelays), true)
+			if tt.wantErr == "" {
+				assert.NilError(t, err)
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			assert.Equal(t, err.Error(), tt.wantErr)
+		})
+	}
+}
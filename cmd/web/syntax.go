@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// A language snippets can be tagged with. Value is the chroma lexer name and
+// Extension is used for the downloaded file names.
+type language struct {
+	Value     string
+	Label     string
+	Extension string
+}
+
+// Languages offered on the snippet forms. The empty value means plain text.
+var languages = []language{
+	{"", "Plain text", ".txt"},
+	{"bash", "Bash", ".sh"},
+	{"c", "C", ".c"},
+	{"cpp", "C++", ".cpp"},
+	{"css", "CSS", ".css"},
+	{"docker", "Dockerfile", ".dockerfile"},
+	{"go", "Go", ".go"},
+	{"html", "HTML", ".html"},
+	{"java", "Java", ".java"},
+	{"javascript", "JavaScript", ".js"},
+	{"json", "JSON", ".json"},
+	{"python", "Python", ".py"},
+	{"ruby", "Ruby", ".rb"},
+	{"rust", "Rust", ".rs"},
+	{"sql", "SQL", ".sql"},
+	{"typescript", "TypeScript", ".ts"},
+	{"yaml", "YAML", ".yaml"},
+}
+
+// Return the values of the supported languages
+func languageValues() []string {
+	values := make([]string, len(languages))
+	for i, l := range languages {
+		values[i] = l.Value
+	}
+	return values
+}
+
+// Return the file extension of a language, .txt if it's unknown
+func languageExtension(value string) string {
+	for _, l := range languages {
+		if l.Value == value {
+			return l.Extension
+		}
+	}
+	return ".txt"
+}
+
+// Classes are used instead of inline styles so the output complies with the
+// Content-Security-Policy. The matching stylesheet, ui/static/css/chroma.css,
+// is the output of syntaxFormatter.WriteCSS for the syntaxStyle style.
+var (
+	syntaxFormatter = chromahtml.New(chromahtml.WithClasses(true))
+	syntaxStyle     = styles.Get("github")
+)
+
+// Return the content as highlighted HTML for the given language. Unknown
+// languages, and any highlighting failure, fall back to escaped plain text.
+func highlightCode(content, language string) template.HTML {
+	plain := template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+
+	lexer := lexers.Get(language)
+	if language == "" || lexer == nil {
+		return plain
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return plain
+	}
+
+	buffer := new(bytes.Buffer)
+	err = syntaxFormatter.Format(buffer, syntaxStyle, iterator)
+	if err != nil {
+		return plain
+	}
+
+	return template.HTML(buffer.String())
+}
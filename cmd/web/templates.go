@@ -1,24 +1,39 @@
 package main
 
 import (
+	"bytes"
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
 
 	"snippetbox.flaviogalon.github.io/internal/models"
 	"snippetbox.flaviogalon.github.io/ui"
 )
 
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
-	User            *models.User
+	CurrentYear         int
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+	User                *models.User
+	Tokens              []*models.Token
+	NewToken            string
+	Filter              models.SnippetFilter
+	NextPage            string
+	PrevPage            string
+	Query               string
 }
 
 // Return a formatted string from a Time object
@@ -30,8 +45,97 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+var (
+	// Raw HTML is never rendered by goldmark unless the html.WithUnsafe
+	// option is set
+	markdownRenderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	// The sanitizer is a second line of defence, it also drops any style
+	// attribute that would be blocked by the Content-Security-Policy
+	markdownPolicy = bluemonday.UGCPolicy()
+)
+
+// Return the Markdown text rendered as sanitized HTML
+func markdown(text string) template.HTML {
+	buffer := new(bytes.Buffer)
+
+	err := markdownRenderer.Convert([]byte(text), buffer)
+	if err != nil {
+		return template.HTML("<pre>" + template.HTMLEscapeString(text) + "</pre>")
+	}
+
+	return template.HTML(markdownPolicy.SanitizeReader(buffer).String())
+}
+
+// Return a case-insensitive regex matching any of the words of a search query
+// or nil if the query has none
+func queryTermsRX(query string) *regexp.Regexp {
+	terms := []string{}
+	for _, term := range strings.Fields(query) {
+		terms = append(terms, regexp.QuoteMeta(term))
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(terms, "|"))
+}
+
+// Return the HTML escaped text with the words of the query wrapped in <mark>
+func highlight(text, query string) template.HTML {
+	rx := queryTermsRX(query)
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return template.HTML(b.String())
+}
+
+// Return at most n characters of the text, centred on the first word of the
+// query found in it
+func excerpt(text, query string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+
+	start := 0
+	if rx := queryTermsRX(query); rx != nil {
+		if loc := rx.FindStringIndex(text); loc != nil {
+			matchStart := utf8.RuneCountInString(text[:loc[0]])
+			matchLen := utf8.RuneCountInString(text[loc[0]:loc[1]])
+			start = max(matchStart-(n-matchLen)/2, 0)
+		}
+	}
+	end := min(start+n, len(runes))
+	start = max(end-n, 0)
+
+	result := string(runes[start:end])
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(runes) {
+		result += "…"
+	}
+	return result
+}
+
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":     humanDate,
+	"markdown":      markdown,
+	"highlight":     highlight,
+	"excerpt":       excerpt,
+	"highlightCode": highlightCode,
+	"languages":     func() []language { return languages },
 }
 
 // Return cache of page templates mapped by template name
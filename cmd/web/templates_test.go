@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -39,3 +40,175 @@ func TestHumanDate(t *testing.T) {
 	}
 
 }
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "Single word",
+			text:     "An old silent pond",
+			query:    "pond",
+			expected: "An old silent <mark>pond</mark>",
+		},
+		{
+			name:     "Case insensitive",
+			text:     "Pond after pond",
+			query:    "POND",
+			expected: "<mark>Pond</mark> after <mark>pond</mark>",
+		},
+		{
+			name:     "Several words",
+			text:     "A frog jumps into the pond",
+			query:    "frog pond",
+			expected: "A <mark>frog</mark> jumps into the <mark>pond</mark>",
+		},
+		{
+			name:     "Escaped text",
+			text:     "<script>pond</script>",
+			query:    "pond",
+			expected: "&lt;script&gt;<mark>pond</mark>&lt;/script&gt;",
+		},
+		{
+			name:     "Regex characters",
+			text:     "a+b (c)",
+			query:    "a+b",
+			expected: "<mark>a+b</mark> (c)",
+		},
+		{
+			name:     "Empty query",
+			text:     "An old silent pond",
+			query:    "",
+			expected: "An old silent pond",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(highlight(tt.text, tt.query)), tt.expected)
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		query    string
+		n        int
+		expected string
+	}{
+		{
+			name:     "Short text",
+			text:     "An old silent pond",
+			query:    "pond",
+			n:        50,
+			expected: "An old silent pond",
+		},
+		{
+			name:     "Centred on match",
+			text:     "An old silent pond, a frog jumps in",
+			query:    "pond",
+			n:        10,
+			expected: "…nt pond, a…",
+		},
+		{
+			name:     "No match",
+			text:     "An old silent pond",
+			query:    "frog",
+			n:        6,
+			expected: "An old…",
+		},
+		{
+			name:     "Match at the end",
+			text:     "An old silent pond",
+			query:    "pond",
+			n:        6,
+			expected: "…t pond",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, excerpt(tt.text, tt.query, tt.n), tt.expected)
+		})
+	}
+}
+
+func TestHighlightCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		expected string
+	}{
+		{
+			name:     "Known language",
+			content:  "package main",
+			language: "go",
+			expected: `<pre class="chroma"><code><span class="line"><span class="cl"><span class="kn">package</span> <span class="nx">main</span></span></span></code></pre>`,
+		},
+		{
+			name:     "Plain text",
+			content:  "<b>bold</b>",
+			language: "",
+			expected: "<pre><code>&lt;b&gt;bold&lt;/b&gt;</code></pre>",
+		},
+		{
+			name:     "Unknown language",
+			content:  "<b>bold</b>",
+			language: "klingon",
+			expected: "<pre><code>&lt;b&gt;bold&lt;/b&gt;</code></pre>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := string(highlightCode(tt.content, tt.language))
+
+			assert.Equal(t, code, tt.expected)
+
+			if strings.Contains(code, "style=") {
+				t.Errorf("inline style found in %q", code)
+			}
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			name:     "Emphasis",
+			text:     "An *old* silent pond",
+			expected: "<p>An <em>old</em> silent pond</p>\n",
+		},
+		{
+			name:     "Raw HTML",
+			text:     "<script>alert(1)</script>",
+			expected: "\n",
+		},
+		{
+			name:     "Inline style",
+			text:     `<span style="color: red">pond</span>`,
+			expected: "<p>pond</p>\n",
+		},
+		{
+			name:     "Javascript link",
+			text:     "[pond](javascript:alert(1))",
+			expected: "<p>pond</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(markdown(tt.text)), tt.expected)
+		})
+	}
+}
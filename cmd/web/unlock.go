@@ -0,0 +1,74 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Number of wrong passwords that can be entered for a snippet within
+// unlockLockout before its unlock form refuses every attempt for
+// unlockLockout
+const (
+	maxUnlockFailures = 5
+	unlockLockout     = 15 * time.Minute
+)
+
+// Wrong passwords entered for the protected snippets. They're counted per
+// snippet rather than per visitor, as starting a new session is free, so a
+// lockout applies to everyone but the visitors who already unlocked the
+// snippet and its author. Safe for concurrent use.
+type unlockLimiter struct {
+	mu       sync.Mutex
+	snippets map[int]*unlockFailures
+	// Replaced by the tests
+	now func() time.Time
+}
+
+type unlockFailures struct {
+	count int
+	// End of the window the failures are counted in, or of the lockout
+	// once count reaches maxUnlockFailures
+	until time.Time
+}
+
+func newUnlockLimiter() *unlockLimiter {
+	return &unlockLimiter{
+		snippets: map[int]*unlockFailures{},
+		now:      time.Now,
+	}
+}
+
+// Report whether the unlock form of the snippet is locked
+func (l *unlockLimiter) lockedOut(snippetID int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.snippets[snippetID]
+	return ok && f.count >= maxUnlockFailures && l.now().Before(f.until)
+}
+
+// Count a wrong password entered for the snippet, locking its unlock form
+// once maxUnlockFailures are reached
+func (l *unlockLimiter) recordFailure(snippetID int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	// Forget the windows and lockouts that are over, so that the map
+	// doesn't grow forever
+	for id, f := range l.snippets {
+		if !now.Before(f.until) {
+			delete(l.snippets, id)
+		}
+	}
+
+	f, ok := l.snippets[snippetID]
+	if !ok {
+		f = &unlockFailures{until: now.Add(unlockLockout)}
+		l.snippets[snippetID] = f
+	}
+	f.count++
+	if f.count == maxUnlockFailures {
+		f.until = now.Add(unlockLockout)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+)
+
+func TestUnlockLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	l := newUnlockLimiter()
+	l.now = func() time.Time { return now }
+
+	for range maxUnlockFailures - 1 {
+		l.recordFailure(1)
+	}
+	assert.Equal(t, l.lockedOut(1), false)
+
+	// Failures older than the window are forgotten
+	now = now.Add(unlockLockout)
+	l.recordFailure(1)
+	assert.Equal(t, l.lockedOut(1), false)
+
+	for range maxUnlockFailures - 1 {
+		l.recordFailure(1)
+	}
+	assert.Equal(t, l.lockedOut(1), true)
+	// Other snippets aren't affected
+	assert.Equal(t, l.lockedOut(2), false)
+
+	now = now.Add(unlockLockout - time.Second)
+	assert.Equal(t, l.lockedOut(1), true)
+
+	now = now.Add(time.Second)
+	assert.Equal(t, l.lockedOut(1), false)
+}
@@ -0,0 +1,67 @@
+// Package logging builds the structured loggers of the application. Records
+// logged with a context carrying a request ID are tagged with it, which ties
+// together every line written while serving a request.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Output formats accepted by New
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type contextKey string
+
+const requestIDContextKey = contextKey("requestID")
+
+// Return a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, id)
+}
+
+// Return the request ID carried by ctx, or "" if there's none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// Build a logger writing records to w as logfmt-style text or JSON
+func New(w io.Writer, format string) (*slog.Logger, error) {
+	var handler slog.Handler
+	switch format {
+	case FormatText:
+		handler = slog.NewTextHandler(w, nil)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, nil)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	return slog.New(ContextHandler{handler}), nil
+}
+
+// Wraps a handler to add the request ID found in the context of each record
+type ContextHandler struct {
+	slog.Handler
+}
+
+func (h ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return ContextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h ContextHandler) WithGroup(name string) slog.Handler {
+	return ContextHandler{h.Handler.WithGroup(name)}
+}
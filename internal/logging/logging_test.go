@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+)
+
+func TestRequestIDAttribute(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger, err := New(&buffer, FormatJSON)
+	assert.NilError(t, err)
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	logger.With("component", "test").InfoContext(ctx, "hello")
+
+	var record map[string]any
+	err = json.Unmarshal(buffer.Bytes(), &record)
+	assert.NilError(t, err)
+
+	assert.Equal(t, record["msg"], any("hello"))
+	assert.Equal(t, record["component"], any("test"))
+	assert.Equal(t, record["request_id"], any("abc123"))
+}
+
+func TestNoRequestID(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger, err := New(&buffer, FormatText)
+	assert.NilError(t, err)
+
+	logger.Info("hello")
+
+	assert.Equal(t, bytes.Contains(buffer.Bytes(), []byte("request_id")), false)
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "xml")
+
+	assert.Equal(t, err != nil, true)
+}
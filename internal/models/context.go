@@ -0,0 +1,20 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Longest time a query may take when the model has no QueryTimeout, so that
+// a slow database doesn't hold requests past the server write timeout
+const DefaultQueryTimeout = 3 * time.Second
+
+// Derive the context of a query from the caller's, with the given timeout or
+// DefaultQueryTimeout when 0. Queries running past it fail with
+// context.DeadlineExceeded.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		timeout = DefaultQueryTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
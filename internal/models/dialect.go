@@ -0,0 +1,142 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQL flavour of the database the models run against. The queries are
+// written with "?" placeholders and rewritten for the dialects using another
+// syntax. The zero value is MySQL.
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// Every supported dialect
+var Dialects = []Dialect{MySQL, SQLite, Postgres}
+
+// Return the dialect with the given name
+func ParseDialect(name string) (Dialect, error) {
+	for _, d := range Dialects {
+		if string(d) == name {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("models: unknown dialect %q", name)
+}
+
+// Name of the database/sql driver registered for the dialect
+func (d Dialect) DriverName() string {
+	switch d {
+	case SQLite:
+		return "sqlite"
+	case Postgres:
+		return "pgx"
+	default:
+		return "mysql"
+	}
+}
+
+// Rewrite the "?" placeholders of a query into the dialect syntax. Question
+// marks in string literals and quoted identifiers are left alone.
+func (d Dialect) rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	// Quote character of the literal or identifier being read, 0 outside.
+	// A doubled quote escaping one closes and reopens it, which is harmless.
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Anything that can run queries: *sql.DB or *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Run an INSERT statement and return the ID of the new row. PostgreSQL
+// doesn't report it through LastInsertId, so it's read with RETURNING.
+func (d Dialect) insert(ctx context.Context, db querier, query string, args ...any) (int, error) {
+	if d == Postgres {
+		var id int
+		err := db.QueryRowContext(ctx, d.rebind(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := db.ExecContext(ctx, d.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Report whether err is a violation of a unique constraint. constraint is
+// the name of the constraint as reported by MySQL and PostgreSQL, column the
+// "table.column" reported by SQLite which doesn't name them.
+func isDuplicateKey(err error, constraint, column string) bool {
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) {
+		// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_dup_entry
+		return mysqlError.Number == 1062 && strings.Contains(mysqlError.Message, constraint)
+	}
+
+	var sqliteError *sqlite.Error
+	if errors.As(err, &sqliteError) {
+		return sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE &&
+			strings.Contains(sqliteError.Error(), column)
+	}
+
+	var pgError *pgconn.PgError
+	if errors.As(err, &pgError) {
+		// https://www.postgresql.org/docs/current/errcodes-appendix.html
+		return pgError.Code == "23505" && pgError.ConstraintName == constraint
+	}
+
+	return false
+}
+
+// Current time as stored in the database. Timestamps are computed here
+// rather than with the SQL functions, which differ between dialects, and
+// truncated to the second so that SQLite can compare them as text.
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
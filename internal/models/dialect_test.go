@@ -0,0 +1,257 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/pgxstore"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+)
+
+func TestDialectRebind(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{
+			name:    "MySQL",
+			dialect: MySQL,
+			query:   "SELECT id FROM users WHERE email = ? AND id > ?",
+			want:    "SELECT id FROM users WHERE email = ? AND id > ?",
+		},
+		{
+			name:    "SQLite",
+			dialect: SQLite,
+			query:   "SELECT id FROM users WHERE email = ? AND id > ?",
+			want:    "SELECT id FROM users WHERE email = ? AND id > ?",
+		},
+		{
+			name:    "PostgreSQL",
+			dialect: Postgres,
+			query:   "SELECT id FROM users WHERE email = ? AND id > ?",
+			want:    "SELECT id FROM users WHERE email = $1 AND id > $2",
+		},
+		{
+			name:    "PostgreSQL string literal",
+			dialect: Postgres,
+			query:   "SELECT id FROM snippets WHERE title <> 'Why?' AND content <> 'It''s ?' AND id > ?",
+			want:    "SELECT id FROM snippets WHERE title <> 'Why?' AND content <> 'It''s ?' AND id > $1",
+		},
+		{
+			name:    "PostgreSQL quoted identifier",
+			dialect: Postgres,
+			query:   `SELECT "what?" FROM snippets WHERE id = ?`,
+			want:    `SELECT "what?" FROM snippets WHERE id = $1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.dialect.rebind(tt.query), tt.want)
+		})
+	}
+}
+
+func TestSQLiteUserModel(t *testing.T) {
+	testDialectUserModel(t, UserModel{DB: newTestSQLiteDB(t), Dialect: SQLite, BcryptCost: 4})
+}
+
+func TestPostgresUserModel(t *testing.T) {
+	testDialectUserModel(t, UserModel{DB: newTestPostgresDB(t), Dialect: Postgres, BcryptCost: 4})
+}
+
+// Insert a user, reading its ID back, and run into the unique email
+// constraint
+func testDialectUserModel(t *testing.T, m UserModel) {
+	ctx := context.Background()
+
+	err := m.Insert(ctx, "Bob", "bob@example.com", "pa55word")
+	assert.NilError(t, err)
+
+	err = m.Insert(ctx, "Bobby", "bob@example.com", "pa55word")
+	assert.Equal(t, errors.Is(err, ErrDuplicateEmail), true)
+
+	id, err := m.Authenticate(ctx, "bob@example.com", "pa55word")
+	assert.NilError(t, err)
+
+	user, err := m.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Name, "Bob")
+
+	exists, err := m.Exists(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, true)
+}
+
+func TestSQLiteSnippetModel(t *testing.T) {
+	testDialectSnippetModel(t, SnippetModel{DB: newTestSQLiteDB(t), Dialect: SQLite, BcryptCost: 4})
+}
+
+func TestMySQLSnippetModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration tests")
+	}
+	testDialectSnippetModel(t, SnippetModel{DB: newTestDB(t), BcryptCost: 4})
+}
+
+func TestPostgresSnippetModel(t *testing.T) {
+	testDialectSnippetModel(t, SnippetModel{DB: newTestPostgresDB(t), Dialect: Postgres, BcryptCost: 4})
+}
+
+// Run the queries written differently for each dialect: the inserts, view
+// counting, search and purge of the expired snippets
+func testDialectSnippetModel(t *testing.T, m SnippetModel) {
+	ctx := context.Background()
+
+	fields := SnippetFields{
+		Title:      "An old silent pond",
+		Content:    "A frog jumps into the pond, splash!",
+		Format:     FormatPlain,
+		Visibility: VisibilityPublic,
+	}
+
+	id, err := m.Insert(ctx, 1, fields, SnippetOptions{MaxViews: 2})
+	assert.NilError(t, err)
+
+	// The first view leaves one, the second burns the snippet
+	snippet, err := m.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, *snippet.RemainingViews, 1)
+
+	snippet, err = m.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.LastView(), true)
+
+	_, err = m.Get(ctx, id)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	expiredID, err := m.Insert(ctx, 1, fields, SnippetOptions{Expires: time.Now().Add(-time.Hour)})
+	assert.NilError(t, err)
+
+	_, err = m.Peek(ctx, expiredID)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	permanentID, err := m.Insert(ctx, 1, fields, SnippetOptions{})
+	assert.NilError(t, err)
+
+	snippets, err := m.Search(ctx, "FROG", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].ID, permanentID)
+
+	snippets, err = m.Search(ctx, "100%", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+
+	deleted, err := m.DeleteExpired(ctx, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, deleted, 2)
+
+	page, err := m.List(ctx, SnippetFilter{IncludeExpired: true})
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 1)
+}
+
+func TestSQLiteSnippetModelUpdate(t *testing.T) {
+	testDialectSnippetModelUpdate(t, SnippetModel{DB: newTestSQLiteDB(t), Dialect: SQLite, BcryptCost: 4})
+}
+
+func TestMySQLSnippetModelUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration tests")
+	}
+	testDialectSnippetModelUpdate(t, SnippetModel{DB: newTestDB(t), BcryptCost: 4})
+}
+
+func TestPostgresSnippetModelUpdate(t *testing.T) {
+	testDialectSnippetModelUpdate(t, SnippetModel{DB: newTestPostgresDB(t), Dialect: Postgres, BcryptCost: 4})
+}
+
+// Edit a snippet, which gets a new slug when it stops being public
+func testDialectSnippetModelUpdate(t *testing.T, m SnippetModel) {
+	ctx := context.Background()
+
+	fields := SnippetFields{
+		Title:      "An old silent pond",
+		Content:    "A frog jumps into the pond, splash!",
+		Format:     FormatPlain,
+		Visibility: VisibilityPublic,
+	}
+
+	id, err := m.Insert(ctx, 1, fields, SnippetOptions{})
+	assert.NilError(t, err)
+	public, err := m.Peek(ctx, id)
+	assert.NilError(t, err)
+
+	// Editing a public snippet keeps its slug
+	fields.Title = "Over the wintry forest"
+	assert.NilError(t, m.Update(ctx, id, fields))
+	edited, err := m.Peek(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, edited.Title, fields.Title)
+	assert.Equal(t, edited.Slug, public.Slug)
+
+	// Making it unlisted issues a new one
+	fields.Visibility = VisibilityUnlisted
+	assert.NilError(t, m.Update(ctx, id, fields))
+	unlisted, err := m.Peek(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, unlisted.Visibility, VisibilityUnlisted)
+	assert.Equal(t, unlisted.Slug != public.Slug, true)
+
+	_, err = m.PeekBySlug(ctx, public.Slug)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	// Unlisted snippets keep theirs
+	assert.NilError(t, m.Update(ctx, id, fields))
+	snippet, err := m.Peek(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Slug, unlisted.Slug)
+
+	// Expired and missing snippets can't be edited
+	expiredID, err := m.Insert(ctx, 1, fields, SnippetOptions{Expires: time.Now().Add(-time.Hour)})
+	assert.NilError(t, err)
+	err = m.Update(ctx, expiredID, fields)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	err = m.Update(ctx, expiredID+1, fields)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
+
+// The sessions table created by the migrations suits the PostgreSQL session
+// store, which runs on its own pgx pool
+func TestPostgresSessionStore(t *testing.T) {
+	newTestPostgresDB(t)
+
+	pool, err := pgxpool.New(context.Background(), os.Getenv("SNIPPETBOX_TEST_POSTGRES_DSN"))
+	assert.NilError(t, err)
+	defer pool.Close()
+	store := pgxstore.NewWithCleanupInterval(pool, 0)
+
+	err = store.Commit("active", []byte("data"), time.Now().Add(time.Minute))
+	assert.NilError(t, err)
+	err = store.Commit("expired", []byte("data"), time.Now().Add(-time.Minute))
+	assert.NilError(t, err)
+
+	b, found, err := store.Find("active")
+	assert.NilError(t, err)
+	assert.Equal(t, found, true)
+	assert.Equal(t, string(b), "data")
+
+	_, found, err = store.Find("expired")
+	assert.NilError(t, err)
+	assert.Equal(t, found, false)
+
+	err = store.Delete("active")
+	assert.NilError(t, err)
+	_, found, err = store.Find("active")
+	assert.NilError(t, err)
+	assert.Equal(t, found, false)
+}
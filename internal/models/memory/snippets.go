@@ -0,0 +1,283 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"snippetbox.flaviogalon.github.io/internal/models"
+)
+
+type SnippetModel struct {
+	DB *DB
+	// Cost of the access password hashes, defaults to models.DefaultBcryptCost
+	BcryptCost int
+}
+
+func expired(s *models.Snippet, now time.Time) bool {
+	return !s.Expires.IsZero() && !s.Expires.After(now)
+}
+
+func burned(s *models.Snippet) bool {
+	return s.RemainingViews != nil && *s.RemainingViews <= 0
+}
+
+// Return a copy of a stored snippet, so that callers can't modify it, along
+// with the name of its author. The caller holds db.mu.
+func (db *DB) snippetCopy(s *models.Snippet) *models.Snippet {
+	c := *s
+	if s.RemainingViews != nil {
+		views := *s.RemainingViews
+		c.RemainingViews = &views
+	}
+	if u := db.user(s.UserID); u != nil {
+		c.UserName = u.Name
+	}
+	return &c
+}
+
+// Return the stored snippet with the given ID, unexpired and with views
+// left, or nil. The caller holds db.mu.
+func (db *DB) snippet(id int, now time.Time) *models.Snippet {
+	for _, s := range db.snippets {
+		if s.ID == id && !expired(s, now) && !burned(s) {
+			return s
+		}
+	}
+	return nil
+}
+
+// Insert a new snippet owned by the given user
+func (m *SnippetModel) Insert(ctx context.Context, userID int, fields models.SnippetFields, options models.SnippetOptions) (int, error) {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	snippet := &models.Snippet{
+		UserID:     userID,
+		Title:      fields.Title,
+		Content:    fields.Content,
+		Language:   fields.Language,
+		Format:     fields.Format,
+		Visibility: fields.Visibility,
+		Slug:       slug,
+		Created:    models.Now(),
+	}
+	if !options.Expires.IsZero() {
+		snippet.Expires = options.Expires.UTC().Truncate(time.Second)
+	}
+	if options.Password != "" {
+		snippet.HashedPassword, err = models.HashPassword(options.Password, m.BcryptCost)
+		if err != nil {
+			return 0, err
+		}
+		snippet.Protected = true
+	}
+	if options.MaxViews > 0 {
+		views := options.MaxViews
+		snippet.RemainingViews = &views
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.lastSnippetID++
+	snippet.ID = m.DB.lastSnippetID
+	m.DB.snippets = append(m.DB.snippets, snippet)
+
+	return snippet.ID, nil
+}
+
+// Get a specific snippet by ID, whatever its visibility, to read its content.
+// This uses up one of the views of a view limited snippet.
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	snippet := m.DB.snippet(id, models.Now())
+	if snippet == nil {
+		return nil, models.ErrNoRecord
+	}
+
+	if snippet.RemainingViews != nil {
+		*snippet.RemainingViews--
+	}
+
+	return m.DB.snippetCopy(snippet), nil
+}
+
+// Get a specific snippet by ID, whatever its visibility, without counting a
+// view
+func (m *SnippetModel) Peek(ctx context.Context, id int) (*models.Snippet, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	snippet := m.DB.snippet(id, models.Now())
+	if snippet == nil {
+		return nil, models.ErrNoRecord
+	}
+
+	return m.DB.snippetCopy(snippet), nil
+}
+
+// Get a specific public or unlisted snippet by slug without counting a view
+func (m *SnippetModel) PeekBySlug(ctx context.Context, slug string) (*models.Snippet, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	now := models.Now()
+	for _, s := range m.DB.snippets {
+		if s.Slug == slug && !expired(s, now) && !burned(s) &&
+			(s.Visibility == models.VisibilityPublic || s.Visibility == models.VisibilityUnlisted) {
+			return m.DB.snippetCopy(s), nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+// Update the editable fields of an unexpired snippet. A public snippet made
+// unlisted or private gets a new slug. Returns models.ErrNoRecord when the
+// snippet doesn't exist or expired.
+func (m *SnippetModel) Update(ctx context.Context, id int, fields models.SnippetFields) error {
+	slug, err := models.NewSlug()
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	now := models.Now()
+	for _, s := range m.DB.snippets {
+		if s.ID == id && !expired(s, now) {
+			if s.Visibility == models.VisibilityPublic && fields.Visibility != models.VisibilityPublic {
+				s.Slug = slug
+			}
+			s.Title = fields.Title
+			s.Content = fields.Content
+			s.Language = fields.Language
+			s.Format = fields.Format
+			s.Visibility = fields.Visibility
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+// Delete a snippet by ID
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for i, s := range m.DB.snippets {
+		if s.ID == id {
+			m.DB.snippets = slices.Delete(m.DB.snippets, i, i+1)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+// Delete at most limit snippets that expired or whose views were all used up,
+// returning how many were deleted
+func (m *SnippetModel) DeleteExpired(ctx context.Context, limit int) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	now := models.Now()
+	deleted := 0
+	m.DB.snippets = slices.DeleteFunc(m.DB.snippets, func(s *models.Snippet) bool {
+		if deleted < limit && (expired(s, now) || burned(s)) {
+			deleted++
+			return true
+		}
+		return false
+	})
+
+	return deleted, nil
+}
+
+// Return a page of snippets matching the filter
+func (m *SnippetModel) List(ctx context.Context, filter models.SnippetFilter) (*models.SnippetPage, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	now := models.Now()
+	ascending := filter.Ascending()
+	cursor := filter.Cursor()
+
+	// Snippets are stored by increasing ID
+	candidates := slices.Clone(m.DB.snippets)
+	if !ascending {
+		slices.Reverse(candidates)
+	}
+
+	snippets := []*models.Snippet{}
+	for _, s := range candidates {
+		// Only public snippets are listed
+		if s.Visibility != models.VisibilityPublic || burned(s) ||
+			(!filter.IncludeExpired && expired(s, now)) {
+			continue
+		}
+		if cursor > 0 && ((ascending && s.ID <= cursor) || (!ascending && s.ID >= cursor)) {
+			continue
+		}
+
+		snippets = append(snippets, m.DB.snippetCopy(s))
+		// An extra snippet tells whether there's another page in that direction
+		if len(snippets) > filter.Size() {
+			break
+		}
+	}
+
+	return filter.Page(snippets), nil
+}
+
+// Return the unexpired public snippets whose title or content contain the
+// query, ignoring case, newest first. Password protected and view limited
+// snippets are left out as matching them would leak their content.
+func (m *SnippetModel) Search(ctx context.Context, query string, limit int) ([]*models.Snippet, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	now := models.Now()
+	query = strings.ToLower(query)
+
+	snippets := []*models.Snippet{}
+	for i := len(m.DB.snippets) - 1; i >= 0 && len(snippets) < limit; i-- {
+		s := m.DB.snippets[i]
+		if s.Visibility != models.VisibilityPublic || expired(s, now) ||
+			s.Protected || s.RemainingViews != nil {
+			continue
+		}
+		if strings.Contains(strings.ToLower(s.Title), query) ||
+			strings.Contains(strings.ToLower(s.Content), query) {
+			snippets = append(snippets, m.DB.snippetCopy(s))
+		}
+	}
+
+	return snippets, nil
+}
+
+// Return all the unexpired snippets created by a user, newest first,
+// whatever their visibility
+func (m *SnippetModel) ListByUser(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	now := models.Now()
+	snippets := []*models.Snippet{}
+	for i := len(m.DB.snippets) - 1; i >= 0; i-- {
+		s := m.DB.snippets[i]
+		if s.UserID == userID && !expired(s, now) && !burned(s) {
+			snippets = append(snippets, m.DB.snippetCopy(s))
+		}
+	}
+
+	return snippets, nil
+}
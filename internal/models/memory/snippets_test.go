@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+	"snippetbox.flaviogalon.github.io/internal/models"
+)
+
+var haiku = models.SnippetFields{
+	Title:      "An old silent pond",
+	Content:    "A frog jumps into the pond, splash!",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityPublic,
+}
+
+func newTestSnippetModel(t *testing.T) *SnippetModel {
+	db := New()
+	users := &UserModel{DB: db, BcryptCost: 4}
+	err := users.Insert(context.Background(), "Alice Jones", "alice@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SnippetModel{DB: db, BcryptCost: 4}
+}
+
+func TestSnippetModelGet(t *testing.T) {
+	ctx := context.Background()
+	var m models.SnippedModelInterface = newTestSnippetModel(t)
+
+	id, err := m.Insert(ctx, 1, haiku, models.SnippetOptions{})
+	assert.NilError(t, err)
+
+	snippet, err := m.Get(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Title, haiku.Title)
+	assert.Equal(t, snippet.UserName, "Alice Jones")
+
+	bySlug, err := m.PeekBySlug(ctx, snippet.Slug)
+	assert.NilError(t, err)
+	assert.Equal(t, bySlug.ID, id)
+
+	expiredID, err := m.Insert(ctx, 1, haiku, models.SnippetOptions{Expires: time.Now().Add(-time.Minute)})
+	assert.NilError(t, err)
+
+	_, err = m.Peek(ctx, expiredID)
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	protectedID, err := m.Insert(ctx, 1, haiku, models.SnippetOptions{Password: "hunter22"})
+	assert.NilError(t, err)
+
+	protected, err := m.Peek(ctx, protectedID)
+	assert.NilError(t, err)
+	assert.Equal(t, protected.Protected, true)
+	assert.NilError(t, protected.CheckPassword("hunter22"))
+}
+
+func TestSnippetModelViews(t *testing.T) {
+	ctx := context.Background()
+	m := newTestSnippetModel(t)
+
+	id, err := m.Insert(ctx, 1, haiku, models.SnippetOptions{MaxViews: 5})
+	assert.NilError(t, err)
+
+	// Peeking doesn't count
+	snippet, err := m.Peek(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, *snippet.RemainingViews, 5)
+
+	// Concurrent readers can't share a view
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	read := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.Get(ctx, id)
+			if err == nil {
+				mu.Lock()
+				read++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, read, 5)
+
+	_, err = m.Peek(ctx, id)
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	deleted, err := m.DeleteExpired(ctx, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, deleted, 1)
+}
+
+func TestSnippetModelList(t *testing.T) {
+	ctx := context.Background()
+	m := newTestSnippetModel(t)
+
+	for range 5 {
+		_, err := m.Insert(ctx, 1, haiku, models.SnippetOptions{})
+		assert.NilError(t, err)
+	}
+	private := haiku
+	private.Visibility = models.VisibilityPrivate
+	_, err := m.Insert(ctx, 1, private, models.SnippetOptions{})
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name     string
+		filter   models.SnippetFilter
+		wantIDs  []int
+		wantNext int
+		wantPrev int
+	}{
+		{
+			name:     "First page",
+			filter:   models.SnippetFilter{PageSize: 2},
+			wantIDs:  []int{5, 4},
+			wantNext: 4,
+		},
+		{
+			name:     "Next page",
+			filter:   models.SnippetFilter{PageSize: 2, After: 4},
+			wantIDs:  []int{3, 2},
+			wantNext: 2,
+			wantPrev: 3,
+		},
+		{
+			name:     "Previous page",
+			filter:   models.SnippetFilter{PageSize: 2, Before: 3},
+			wantIDs:  []int{5, 4},
+			wantNext: 4,
+		},
+		{
+			name:     "Oldest first",
+			filter:   models.SnippetFilter{PageSize: 3, Sort: models.SortOldest, After: 3},
+			wantIDs:  []int{4, 5},
+			wantPrev: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := m.List(ctx, tt.filter)
+			assert.NilError(t, err)
+
+			ids := []int{}
+			for _, snippet := range page.Snippets {
+				ids = append(ids, snippet.ID)
+			}
+			assert.Equal(t, len(ids), len(tt.wantIDs))
+			for i := range ids {
+				assert.Equal(t, ids[i], tt.wantIDs[i])
+			}
+			assert.Equal(t, page.Next, tt.wantNext)
+			assert.Equal(t, page.Prev, tt.wantPrev)
+		})
+	}
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	ctx := context.Background()
+	m := newTestSnippetModel(t)
+
+	id, err := m.Insert(ctx, 1, haiku, models.SnippetOptions{})
+	assert.NilError(t, err)
+	_, err = m.Insert(ctx, 1, haiku, models.SnippetOptions{MaxViews: 1})
+	assert.NilError(t, err)
+
+	snippets, err := m.Search(ctx, "FROG", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].ID, id)
+
+	snippets, err = m.Search(ctx, "toad", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+}
+
+func TestSnippetModelUpdate(t *testing.T) {
+	ctx := context.Background()
+	m := newTestSnippetModel(t)
+
+	id, err := m.Insert(ctx, 1, haiku, models.SnippetOptions{})
+	assert.NilError(t, err)
+	public, err := m.Peek(ctx, id)
+	assert.NilError(t, err)
+
+	fields := haiku
+	fields.Title = "Over the wintry forest"
+	assert.NilError(t, m.Update(ctx, id, fields))
+	edited, err := m.Peek(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, edited.Title, fields.Title)
+	assert.Equal(t, edited.Slug, public.Slug)
+
+	// A public snippet made unlisted gets a new slug
+	fields.Visibility = models.VisibilityUnlisted
+	assert.NilError(t, m.Update(ctx, id, fields))
+	unlisted, err := m.Peek(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, unlisted.Slug != public.Slug, true)
+
+	_, err = m.PeekBySlug(ctx, public.Slug)
+	assert.Equal(t, err, models.ErrNoRecord)
+
+	// Expired and missing snippets can't be edited
+	expiredID, err := m.Insert(ctx, 1, fields, models.SnippetOptions{Expires: time.Now().Add(-time.Hour)})
+	assert.NilError(t, err)
+	assert.Equal(t, m.Update(ctx, expiredID, fields), models.ErrNoRecord)
+	assert.Equal(t, m.Update(ctx, expiredID+1, fields), models.ErrNoRecord)
+}
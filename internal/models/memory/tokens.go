@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+
+	"snippetbox.flaviogalon.github.io/internal/models"
+)
+
+// A token and the hash it's authenticated with, the plaintext isn't kept
+type token struct {
+	models.Token
+	hash string
+}
+
+type TokenModel struct {
+	DB *DB
+}
+
+// Generate a new random API token for a user and store its hash
+func (m *TokenModel) New(ctx context.Context, userID int, name string) (*models.Token, error) {
+	randomBytes := make([]byte, 20)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	m.DB.lastTokenID++
+	t := &token{
+		Token: models.Token{
+			ID:      m.DB.lastTokenID,
+			UserID:  userID,
+			Name:    name,
+			Created: models.Now(),
+		},
+		hash: models.HashToken(plaintext),
+	}
+	m.DB.tokens = append(m.DB.tokens, t)
+
+	created := t.Token
+	created.Plaintext = plaintext
+	return &created, nil
+}
+
+// Return the tokens of a user, newest first
+func (m *TokenModel) ListByUser(ctx context.Context, userID int) ([]*models.Token, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	tokens := []*models.Token{}
+	for i := len(m.DB.tokens) - 1; i >= 0; i-- {
+		if t := m.DB.tokens[i]; t.UserID == userID {
+			listed := t.Token
+			tokens = append(tokens, &listed)
+		}
+	}
+
+	return tokens, nil
+}
+
+// Revoke one of the user's tokens
+func (m *TokenModel) Delete(ctx context.Context, id, userID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for i, t := range m.DB.tokens {
+		if t.ID == id && t.UserID == userID {
+			m.DB.tokens = append(m.DB.tokens[:i], m.DB.tokens[i+1:]...)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+// Return the ID of the user owning the token
+func (m *TokenModel) Authenticate(ctx context.Context, plaintext string) (int, error) {
+	hash := models.HashToken(plaintext)
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, t := range m.DB.tokens {
+		if t.hash == hash {
+			return t.UserID, nil
+		}
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
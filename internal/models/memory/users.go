@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+
+	"snippetbox.flaviogalon.github.io/internal/models"
+)
+
+type UserModel struct {
+	DB *DB
+	// Defaults to models.DefaultBcryptCost when 0
+	BcryptCost int
+}
+
+// Create a user
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	// Hashing is slow, so it's done before taking the lock
+	hashedPassword, err := models.HashPassword(password, m.BcryptCost)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, u := range m.DB.users {
+		if u.Email == email {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	m.DB.lastUserID++
+	m.DB.users = append(m.DB.users, &models.User{
+		ID:             m.DB.lastUserID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        models.Now(),
+	})
+
+	return nil
+}
+
+// Authenticate an user
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	// The hash is copied under the lock, as PasswordUpdate may replace it,
+	// and compared once the lock is released as it's slow
+	m.DB.mu.Lock()
+	var id int
+	var hashedPassword []byte
+	for _, u := range m.DB.users {
+		if u.Email == email {
+			id, hashedPassword = u.ID, u.HashedPassword
+			break
+		}
+	}
+	m.DB.mu.Unlock()
+
+	if id == 0 {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	err := models.CheckPassword(hashedPassword, password)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Return true if there's a user with the given id
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	return m.DB.user(id) != nil, nil
+}
+
+// Get a user
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	u := m.DB.user(id)
+	if u == nil {
+		return nil, models.ErrNoRecord
+	}
+
+	// The hash isn't returned, as with the SQL store
+	return &models.User{ID: u.ID, Name: u.Name, Email: u.Email, Created: u.Created}, nil
+}
+
+// Update an user's password
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
+	m.DB.mu.Lock()
+	u := m.DB.user(id)
+	var currentHashedPassword []byte
+	if u != nil {
+		currentHashedPassword = u.HashedPassword
+	}
+	m.DB.mu.Unlock()
+
+	if u == nil {
+		return models.ErrNoRecord
+	}
+
+	err := models.CheckPassword(currentHashedPassword, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	hashedNewPassword, err := models.HashPassword(newPassword, m.BcryptCost)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	u.HashedPassword = hashedNewPassword
+	return nil
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+	"snippetbox.flaviogalon.github.io/internal/models"
+)
+
+func TestUserModel(t *testing.T) {
+	ctx := context.Background()
+	var m models.UserModelInterface = &UserModel{DB: New(), BcryptCost: 4}
+
+	err := m.Insert(ctx, "Alice Jones", "alice@example.com", "pa$$word")
+	assert.NilError(t, err)
+
+	err = m.Insert(ctx, "Alice Smith", "alice@example.com", "pa$$word")
+	assert.Equal(t, errors.Is(err, models.ErrDuplicateEmail), true)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantID   int
+		wantErr  error
+	}{
+		{
+			name:     "Valid credentials",
+			email:    "alice@example.com",
+			password: "pa$$word",
+			wantID:   1,
+		},
+		{
+			name:     "Wrong password",
+			email:    "alice@example.com",
+			password: "password",
+			wantErr:  models.ErrInvalidCredentials,
+		},
+		{
+			name:     "Unknown email",
+			email:    "bob@example.com",
+			password: "pa$$word",
+			wantErr:  models.ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := m.Authenticate(ctx, tt.email, tt.password)
+
+			assert.Equal(t, id, tt.wantID)
+			assert.Equal(t, err, tt.wantErr)
+		})
+	}
+
+	err = m.PasswordUpdate(ctx, 1, "wrong", "new password")
+	assert.Equal(t, err, models.ErrInvalidCredentials)
+
+	err = m.PasswordUpdate(ctx, 1, "pa$$word", "new password")
+	assert.NilError(t, err)
+
+	id, err := m.Authenticate(ctx, "alice@example.com", "new password")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	user, err := m.Get(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Name, "Alice Jones")
+
+	_, err = m.Get(ctx, 2)
+	assert.Equal(t, err, models.ErrNoRecord)
+}
+
+// Logins can run while the password changes, which go test -race checks
+func TestUserModelConcurrentPasswordUpdate(t *testing.T) {
+	ctx := context.Background()
+	m := &UserModel{DB: New(), BcryptCost: 4}
+
+	err := m.Insert(ctx, "Alice Jones", "alice@example.com", "pa$$word")
+	assert.NilError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 10 {
+			m.Authenticate(ctx, "alice@example.com", "pa$$word")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 10 {
+			m.PasswordUpdate(ctx, 1, "pa$$word", "pa$$word")
+		}
+	}()
+	wg.Wait()
+
+	id, err := m.Authenticate(ctx, "alice@example.com", "pa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+}
+
+func TestTokenModel(t *testing.T) {
+	ctx := context.Background()
+	var m models.TokenModelInterface = &TokenModel{DB: New()}
+
+	token, err := m.New(ctx, 1, "CLI")
+	assert.NilError(t, err)
+
+	userID, err := m.Authenticate(ctx, token.Plaintext)
+	assert.NilError(t, err)
+	assert.Equal(t, userID, 1)
+
+	tokens, err := m.ListByUser(ctx, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 1)
+	assert.Equal(t, tokens[0].Plaintext, "")
+
+	// Only the owner can revoke it
+	assert.Equal(t, m.Delete(ctx, token.ID, 2), models.ErrNoRecord)
+	assert.NilError(t, m.Delete(ctx, token.ID, 1))
+
+	_, err = m.Authenticate(ctx, token.Plaintext)
+	assert.Equal(t, err, models.ErrInvalidCredentials)
+}
@@ -0,0 +1,260 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema migrations of every dialect, named VERSION_NAME.up.sql and
+// VERSION_NAME.down.sql in the directory of the dialect
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationFileRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// A versioned change of the schema and the script reverting it
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// A migration and when it was applied, zero if it's pending
+type MigrationStatus struct {
+	Migration
+	Applied time.Time
+}
+
+type MigrationModel struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// Return the migrations of the dialect, sorted by version
+func (m *MigrationModel) migrations() ([]Migration, error) {
+	dialect := m.Dialect
+	if dialect == "" {
+		dialect = MySQL
+	}
+	dir := path.Join("migrations", string(dialect))
+
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		matches := migrationFileRX.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("models: unexpected migration file %s", entry.Name())
+		}
+
+		version, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, err
+		}
+		script, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("models: migrations %s and %s share version %d", migration.Name, matches[2], version)
+		}
+		if matches[3] == "up" {
+			migration.up = string(script)
+		} else {
+			migration.down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("models: migration %d_%s needs both an up and a down script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+
+	return migrations, nil
+}
+
+// Create the table recording the applied migrations if it doesn't exist
+func (m *MigrationModel) init(ctx context.Context) error {
+	sqlQuery := `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied TIMESTAMP NOT NULL
+	)`
+
+	_, err := m.DB.ExecContext(ctx, sqlQuery)
+	return err
+}
+
+// Return the applied versions and when they were applied
+func (m *MigrationModel) applied(ctx context.Context) (map[int]time.Time, error) {
+	err := m.init(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, "SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		err = rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Return every migration, applied or pending, sorted by version
+func (m *MigrationModel) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		statuses = append(statuses, MigrationStatus{migration, applied[migration.Version]})
+	}
+
+	return statuses, nil
+}
+
+// Apply the pending migrations in order, returning the ones applied. It stops
+// at the first failing one, leaving the following ones pending.
+func (m *MigrationModel) Up(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, status := range statuses {
+		if !status.Applied.IsZero() {
+			continue
+		}
+
+		sqlQuery := "INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)"
+		err = m.run(ctx, status.up, sqlQuery, status.Version, status.Name, Now())
+		if err != nil {
+			return done, fmt.Errorf("models: migration %d_%s: %w", status.Version, status.Name, err)
+		}
+		done = append(done, status.Migration)
+	}
+
+	return done, nil
+}
+
+// Revert the latest applied migration and return it, or ErrNoRecord if no
+// migration is applied
+func (m *MigrationModel) Down(ctx context.Context) (*Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		status := statuses[i]
+		if status.Applied.IsZero() {
+			continue
+		}
+
+		sqlQuery := "DELETE FROM schema_migrations WHERE version = ?"
+		err = m.run(ctx, status.down, sqlQuery, status.Version)
+		if err != nil {
+			return nil, fmt.Errorf("models: migration %d_%s: %w", status.Version, status.Name, err)
+		}
+		return &status.Migration, nil
+	}
+
+	return nil, ErrNoRecord
+}
+
+// Run a migration script and the query recording it in a transaction. Schema
+// changes can take long on large tables, so they get no query timeout. MySQL
+// commits implicitly after each schema change though, so a failing migration
+// can leave it half applied there.
+func (m *MigrationModel) run(ctx context.Context, script, sqlQuery string, args ...any) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// No-op once the transaction is committed
+	defer tx.Rollback()
+
+	for _, statement := range splitStatements(script) {
+		_, err = tx.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, m.Dialect.rebind(sqlQuery), args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Split a script into its statements, leaving the comments out. The MySQL
+// driver only runs several statements at once when the DSN allows it.
+// Statements end with a semicolon at the end of a line.
+func splitStatements(script string) []string {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	var statements []string
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";\n") {
+		statement = strings.TrimSuffix(strings.TrimSpace(statement), ";")
+		if statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+
+	return statements
+}
@@ -1,3 +1,6 @@
+-- Baseline schema, as created by the former init.sql script
+
+-- Create a `snippets` table.
 CREATE TABLE snippets (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
     title VARCHAR(100) NOT NULL,
@@ -5,9 +8,18 @@ CREATE TABLE snippets (
     created DATETIME NOT NULL,
     expires DATETIME NOT NULL
 );
-
+-- Add an index on the created column.
 CREATE INDEX idx_snippets_created ON snippets(created);
 
+-- Create a `sessions` table
+CREATE TABLE sessions (
+    token CHAR(43) PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry TIMESTAMP(6) NOT NULL
+);
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+
+-- Create a `users` table
 CREATE TABLE users (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
     name VARCHAR(255) NOT NULL,
@@ -15,18 +27,4 @@ CREATE TABLE users (
     hashed_password CHAR(60) NOT NULL,
     created DATETIME NOT NULL
 );
-
-ALTER TABLE
-    users
-ADD
-    CONSTRAINT users_uc_email UNIQUE (email);
-
-INSERT INTO
-    users (name, email, hashed_password, created)
-VALUES
-    (
-        'Alice Jones',
-        'alice@example.com',
-        '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
-        '2022-01-01 10:00:00'
-    );
\ No newline at end of file
+ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
@@ -0,0 +1,4 @@
+ALTER TABLE snippets DROP FOREIGN KEY fk_snippets_user;
+ALTER TABLE snippets DROP COLUMN user_id;
+
+DELETE FROM users WHERE email = 'anonymous@invalid';
@@ -0,0 +1,12 @@
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL AFTER id;
+
+-- Snippets created before they had an author are given to an "Anonymous"
+-- account nobody can log in to, created only if there are any
+INSERT INTO users (name, email, hashed_password, created)
+SELECT 'Anonymous', 'anonymous@invalid',
+    '$2a$12$N2F2.JJ0rLReCKrTCHvBZOySEbpNas1DPzZoltur.a.PH1mHq5y6S', UTC_TIMESTAMP()
+FROM DUAL WHERE EXISTS (SELECT 1 FROM snippets);
+UPDATE snippets SET user_id = (SELECT id FROM users WHERE email = 'anonymous@invalid');
+
+ALTER TABLE snippets MODIFY user_id INTEGER NOT NULL,
+    ADD CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users(id);
@@ -0,0 +1,2 @@
+-- Add a full-text index used by the search page.
+CREATE FULLTEXT INDEX idx_snippets_fulltext ON snippets(title, content);
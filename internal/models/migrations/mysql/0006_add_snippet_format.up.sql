@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN format VARCHAR(10) NOT NULL DEFAULT 'plain' AFTER language;
@@ -0,0 +1,2 @@
+-- Unlisted and private snippets become public again
+ALTER TABLE snippets DROP COLUMN slug, DROP COLUMN visibility;
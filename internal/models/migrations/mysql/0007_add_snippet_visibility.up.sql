@@ -0,0 +1,10 @@
+-- Existing snippets stay public and are given a random slug
+ALTER TABLE snippets
+    ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public' AFTER format,
+    ADD COLUMN slug CHAR(22) NULL AFTER visibility;
+
+UPDATE snippets
+SET slug = LEFT(REPLACE(REPLACE(TO_BASE64(RANDOM_BYTES(16)), '+', '-'), '/', '_'), 22);
+
+ALTER TABLE snippets MODIFY slug CHAR(22) NOT NULL,
+    ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
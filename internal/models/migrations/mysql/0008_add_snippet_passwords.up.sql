@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN hashed_password CHAR(60) NULL AFTER slug;
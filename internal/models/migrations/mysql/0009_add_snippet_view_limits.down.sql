@@ -0,0 +1,2 @@
+-- View limited snippets can be read any number of times again
+ALTER TABLE snippets DROP COLUMN remaining_views;
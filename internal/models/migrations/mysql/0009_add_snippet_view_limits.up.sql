@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN remaining_views INT NULL AFTER hashed_password;
@@ -0,0 +1,2 @@
+-- Add an index on the expires column used by the janitor.
+CREATE INDEX idx_snippets_expires ON snippets(expires);
@@ -0,0 +1,3 @@
+ALTER TABLE snippets DROP COLUMN user_id;
+
+DELETE FROM users WHERE email = 'anonymous@invalid';
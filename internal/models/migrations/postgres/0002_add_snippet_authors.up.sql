@@ -0,0 +1,13 @@
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+
+-- Snippets created before they had an author are given to an "Anonymous"
+-- account nobody can log in to, created only if there are any
+INSERT INTO users (name, email, hashed_password, created)
+SELECT 'Anonymous', 'anonymous@invalid',
+    '$2a$12$N2F2.JJ0rLReCKrTCHvBZOySEbpNas1DPzZoltur.a.PH1mHq5y6S', (now() AT TIME ZONE 'UTC')
+WHERE EXISTS (SELECT 1 FROM snippets);
+UPDATE snippets SET user_id = (SELECT id FROM users WHERE email = 'anonymous@invalid');
+
+ALTER TABLE snippets ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE snippets
+    ADD CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users(id);
@@ -0,0 +1,3 @@
+-- Add a full-text index used by the search page.
+CREATE INDEX idx_snippets_fulltext ON snippets
+    USING GIN (to_tsvector('english', title || ' ' || content));
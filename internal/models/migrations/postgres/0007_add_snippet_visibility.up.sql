@@ -0,0 +1,10 @@
+-- Existing snippets stay public and are given a random slug
+ALTER TABLE snippets
+    ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    ADD COLUMN slug CHAR(22) NULL;
+
+UPDATE snippets
+SET slug = left(translate(encode(uuid_send(gen_random_uuid()), 'base64'), '+/', '-_'), 22);
+
+ALTER TABLE snippets ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
@@ -0,0 +1,2 @@
+-- Protected snippets lose their password
+ALTER TABLE snippets DROP COLUMN hashed_password;
@@ -0,0 +1,4 @@
+-- Snippets that never expire are given the latest date there is
+UPDATE snippets SET expires = '9999-12-31 23:59:59' WHERE expires IS NULL;
+
+ALTER TABLE snippets ALTER COLUMN expires SET NOT NULL;
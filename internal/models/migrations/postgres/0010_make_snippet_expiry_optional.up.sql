@@ -0,0 +1,2 @@
+-- NULL for the snippets that never expire
+ALTER TABLE snippets ALTER COLUMN expires DROP NOT NULL;
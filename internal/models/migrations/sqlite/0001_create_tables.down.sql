@@ -0,0 +1,5 @@
+DROP TABLE users;
+
+DROP TABLE sessions;
+
+DROP TABLE snippets;
@@ -0,0 +1,24 @@
+-- Snippets created before they had an author are given to an "Anonymous"
+-- account nobody can log in to, created only if there are any
+INSERT INTO users (name, email, hashed_password, created)
+SELECT 'Anonymous', 'anonymous@invalid',
+    '$2a$12$N2F2.JJ0rLReCKrTCHvBZOySEbpNas1DPzZoltur.a.PH1mHq5y6S', datetime('now')
+WHERE EXISTS (SELECT 1 FROM snippets);
+
+-- SQLite can't add a NOT NULL foreign key column, so the table is rebuilt
+CREATE TABLE snippets_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+INSERT INTO snippets_new (id, user_id, title, content, created, expires)
+SELECT id, (SELECT id FROM users WHERE email = 'anonymous@invalid'),
+    title, content, created, expires
+FROM snippets;
+DROP TABLE snippets;
+ALTER TABLE snippets_new RENAME TO snippets;
+CREATE INDEX idx_snippets_created ON snippets(created);
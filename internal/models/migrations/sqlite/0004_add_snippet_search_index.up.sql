@@ -0,0 +1 @@
+-- The search page runs LIKE queries on SQLite, which no index speeds up
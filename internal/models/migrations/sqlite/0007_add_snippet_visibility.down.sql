@@ -0,0 +1,4 @@
+-- Unlisted and private snippets become public again
+DROP INDEX snippets_uc_slug;
+ALTER TABLE snippets DROP COLUMN slug;
+ALTER TABLE snippets DROP COLUMN visibility;
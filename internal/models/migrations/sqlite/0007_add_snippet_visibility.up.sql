@@ -0,0 +1,8 @@
+-- Existing snippets stay public and are given a random slug
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+ALTER TABLE snippets ADD COLUMN slug CHAR(22) NOT NULL DEFAULT '';
+
+UPDATE snippets SET slug = lower(hex(randomblob(11)));
+
+-- SQLite can't add a UNIQUE column, a unique index enforces it instead
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets(slug);
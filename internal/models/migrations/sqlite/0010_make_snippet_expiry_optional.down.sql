@@ -0,0 +1,25 @@
+-- Snippets that never expire are given the latest date there is
+CREATE TABLE snippets_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(50) NOT NULL DEFAULT '',
+    format VARCHAR(10) NOT NULL DEFAULT 'plain',
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    slug CHAR(22) NOT NULL,
+    hashed_password CHAR(60) NULL,
+    remaining_views INT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+INSERT INTO snippets_new (id, user_id, title, content, language, format, visibility,
+    slug, hashed_password, remaining_views, created, expires)
+SELECT id, user_id, title, content, language, format, visibility,
+    slug, hashed_password, remaining_views, created, COALESCE(expires, '9999-12-31 23:59:59')
+FROM snippets;
+DROP TABLE snippets;
+ALTER TABLE snippets_new RENAME TO snippets;
+CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets(slug);
@@ -0,0 +1,26 @@
+-- NULL for the snippets that never expire. SQLite can't change a column, so
+-- the table is rebuilt.
+CREATE TABLE snippets_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(50) NOT NULL DEFAULT '',
+    format VARCHAR(10) NOT NULL DEFAULT 'plain',
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    slug CHAR(22) NOT NULL,
+    hashed_password CHAR(60) NULL,
+    remaining_views INT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NULL,
+    CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+INSERT INTO snippets_new (id, user_id, title, content, language, format, visibility,
+    slug, hashed_password, remaining_views, created, expires)
+SELECT id, user_id, title, content, language, format, visibility,
+    slug, hashed_password, remaining_views, created, expires
+FROM snippets;
+DROP TABLE snippets;
+ALTER TABLE snippets_new RENAME TO snippets;
+CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets(slug);
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.flaviogalon.github.io/internal/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- Create a table
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY
+);
+-- Index it
+CREATE INDEX idx_users_id ON users(id);
+`
+
+	statements := splitStatements(script)
+
+	assert.Equal(t, len(statements), 2)
+	assert.Equal(t, statements[0], "CREATE TABLE users (\n    id INTEGER NOT NULL PRIMARY KEY\n)")
+	assert.Equal(t, statements[1], "CREATE INDEX idx_users_id ON users(id)")
+}
+
+func TestMigrations(t *testing.T) {
+	for _, dialect := range Dialects {
+		t.Run(string(dialect), func(t *testing.T) {
+			m := MigrationModel{Dialect: dialect}
+
+			migrations, err := m.migrations()
+			assert.NilError(t, err)
+
+			// Versions start at 1 and leave no gap
+			for i, migration := range migrations {
+				assert.Equal(t, migration.Version, i+1)
+			}
+		})
+	}
+}
+
+func TestMigrationModel(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLiteDB(t)
+	m := MigrationModel{DB: db, Dialect: SQLite}
+
+	// newTestSQLiteDB already applied them
+	applied, err := m.Up(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), 0)
+
+	statuses, err := m.Status(ctx)
+	assert.NilError(t, err)
+	for _, status := range statuses {
+		assert.Equal(t, status.Applied.IsZero(), false)
+	}
+
+	for range statuses {
+		_, err = m.Down(ctx)
+		assert.NilError(t, err)
+	}
+
+	_, err = m.Down(ctx)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	statuses, err = m.Status(ctx)
+	assert.NilError(t, err)
+	for _, status := range statuses {
+		assert.Equal(t, status.Applied.IsZero(), true)
+	}
+
+	applied, err = m.Up(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), len(statuses))
+}
+
+func TestMigrationUpgrade(t *testing.T) {
+	testMigrationUpgrade(t, newTestSQLiteDB(t), SQLite)
+}
+
+func TestMySQLMigrationUpgrade(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration tests")
+	}
+	testMigrationUpgrade(t, newTestDB(t), MySQL)
+}
+
+func TestPostgresMigrationUpgrade(t *testing.T) {
+	testMigrationUpgrade(t, newTestPostgresDB(t), Postgres)
+}
+
+// Upgrade a database holding a snippet of the baseline schema to the latest
+// one, then revert every migration
+func testMigrationUpgrade(t *testing.T, db *sql.DB, dialect Dialect) {
+	ctx := context.Background()
+	m := MigrationModel{DB: db, Dialect: dialect}
+
+	statuses, err := m.Status(ctx)
+	assert.NilError(t, err)
+	for range statuses[1:] {
+		_, err = m.Down(ctx)
+		assert.NilError(t, err)
+	}
+
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	id, err := dialect.insert(
+		ctx,
+		db,
+		"INSERT INTO snippets (title, content, created, expires) VALUES (?, ?, ?, ?)",
+		"An old silent pond",
+		"A frog jumps into the pond, splash!",
+		Now(),
+		expires,
+	)
+	assert.NilError(t, err)
+
+	applied, err := m.Up(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), len(statuses)-1)
+
+	snippets := SnippetModel{DB: db, Dialect: dialect}
+	snippet, err := snippets.Peek(ctx, id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Title, "An old silent pond")
+	assert.Equal(t, snippet.UserName, "Anonymous")
+	assert.Equal(t, snippet.Format, FormatPlain)
+	assert.Equal(t, snippet.Visibility, VisibilityPublic)
+	assert.Equal(t, len(snippet.Slug), 22)
+	assert.Equal(t, snippet.Protected, false)
+	assert.Equal(t, snippet.RemainingViews == nil, true)
+	assert.Equal(t, snippet.Expires.Equal(expires), true)
+
+	// Nobody can log in as the author of the former snippets
+	users := UserModel{DB: db, Dialect: dialect}
+	_, err = users.Authenticate(ctx, "anonymous@invalid", "")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	// The down migrations keep the data they can
+	for range statuses {
+		_, err = m.Down(ctx)
+		assert.NilError(t, err)
+	}
+}
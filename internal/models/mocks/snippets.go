@@ -1,34 +1,218 @@
 package mocks
 
 import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/crypto/bcrypt"
+
 	"snippetbox.flaviogalon.github.io/internal/models"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	UserID:     1,
+	UserName:   "Alice",
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityPublic,
+	Slug:       "xPb3Y8K0r2mDq9sVnT4wLe",
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+}
+
+var mockUnlistedSnippet = &models.Snippet{
+	ID:         3,
+	UserID:     1,
+	UserName:   "Alice",
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest...",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityUnlisted,
+	Slug:       "G7hJ2kLm9NpQ4rSt6UvWxY",
+	Created:    time.Now(),
+	Expires:    time.Now().Add(24 * time.Hour),
+}
+
+// Protected by the password "hunter22"
+var mockProtectedSnippet = &models.Snippet{
+	ID:             4,
+	UserID:         2,
+	UserName:       "Bob",
+	Title:          "First autumn morning",
+	Content:        "First autumn morning...",
+	Format:         models.FormatPlain,
+	Visibility:     models.VisibilityPublic,
+	Slug:           "Qm4Rz8Wk1Ty6Hb3Nc9Jd2F",
+	Created:        time.Now(),
+	Expires:        time.Now().Add(24 * time.Hour),
+	Protected:      true,
+	HashedPassword: mustHashPassword("hunter22"),
+}
+
+// Burned after one more view
+var mockBurnSnippet = &models.Snippet{
+	ID:             5,
+	UserID:         2,
+	UserName:       "Bob",
+	Title:          "A lightning flash",
+	Content:        "A lightning flash...",
+	Format:         models.FormatPlain,
+	Visibility:     models.VisibilityPublic,
+	Slug:           "Vd5Kp2Xs8Lm3Qw7Zr1Tn6B",
+	Created:        time.Now(),
+	Expires:        time.Now().Add(24 * time.Hour),
+	RemainingViews: intPtr(1),
+}
+
+// Only listed with the expired snippets
+var mockExpiredSnippet = &models.Snippet{
+	ID:         6,
+	UserID:     1,
+	UserName:   "Alice",
+	Title:      "The summer grasses",
+	Content:    "The summer grasses...",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityPublic,
+	Slug:       "Hs7Tf3Mb9Kq2Wx5Rn8Lc4D",
+	Created:    time.Now().Add(-48 * time.Hour),
+	Expires:    time.Now().Add(-24 * time.Hour),
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func mustHashPassword(password string) []byte {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	return hashedPassword
 }
 
-type SnippetModel struct{}
+// ID of a snippet whose lookup runs past the query timeout
+const mockTimeoutSnippetID = 6
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+type SnippetModel struct {
+	mu sync.Mutex
+	// IDs passed to Update and Delete, in call order
+	updated []int
+	deleted []int
+}
+
+// Return the IDs of the snippets Update was called with
+func (m *SnippetModel) UpdatedIDs() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.updated)
+}
+
+// Return the IDs of the snippets Delete was called with
+func (m *SnippetModel) DeletedIDs() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.deleted)
+}
+
+func (m *SnippetModel) Insert(ctx context.Context, userID int, fields models.SnippetFields, options models.SnippetOptions) (int, error) {
 	return 2, nil
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	if id == mockBurnSnippet.ID {
+		// Reading uses up the last view
+		snippet := *mockBurnSnippet
+		snippet.RemainingViews = intPtr(0)
+		return &snippet, nil
+	}
+	return m.Peek(ctx, id)
+}
+
+func (m *SnippetModel) Peek(ctx context.Context, id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockUnlistedSnippet, nil
+	case 4:
+		return mockProtectedSnippet, nil
+	case 5:
+		return mockBurnSnippet, nil
+	case mockTimeoutSnippetID:
+		return nil, context.DeadlineExceeded
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	return []*models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) PeekBySlug(ctx context.Context, slug string) (*models.Snippet, error) {
+	switch slug {
+	case mockSnippet.Slug:
+		return mockSnippet, nil
+	case mockUnlistedSnippet.Slug:
+		return mockUnlistedSnippet, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) List(ctx context.Context, filter models.SnippetFilter) (*models.SnippetPage, error) {
+	if filter.After > 0 || filter.Before > 0 {
+		return &models.SnippetPage{Snippets: []*models.Snippet{}}, nil
+	}
+	snippets := []*models.Snippet{mockSnippet}
+	if filter.IncludeExpired {
+		snippets = append(snippets, mockExpiredSnippet)
+	}
+	return &models.SnippetPage{Snippets: snippets}, nil
+}
+
+func (m *SnippetModel) Search(ctx context.Context, query string, limit int) ([]*models.Snippet, error) {
+	if strings.Contains(strings.ToLower(mockSnippet.Content), strings.ToLower(query)) {
+		return []*models.Snippet{mockSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) ListByUser(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	if userID == mockSnippet.UserID {
+		return []*models.Snippet{mockUnlistedSnippet, mockSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id int, fields models.SnippetFields) error {
+	m.mu.Lock()
+	m.updated = append(m.updated, id)
+	m.mu.Unlock()
+
+	// The other snippets expired or were deleted since they were read
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	m.deleted = append(m.deleted, id)
+	m.mu.Unlock()
+
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) DeleteExpired(ctx context.Context, limit int) (int, error) {
+	return 0, nil
 }
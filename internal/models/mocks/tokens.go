@@ -0,0 +1,49 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"snippetbox.flaviogalon.github.io/internal/models"
+)
+
+var mockToken = &models.Token{
+	ID:      1,
+	UserID:  1,
+	Name:    "CI",
+	Created: time.Now(),
+}
+
+type TokenModel struct{}
+
+func (m *TokenModel) New(ctx context.Context, userID int, name string) (*models.Token, error) {
+	token := &models.Token{
+		ID:        2,
+		UserID:    userID,
+		Name:      name,
+		Created:   time.Now(),
+		Plaintext: "NEWMOCKTOKENNEWMOCKTOKENNEWMOCKT",
+	}
+	return token, nil
+}
+
+func (m *TokenModel) ListByUser(ctx context.Context, userID int) ([]*models.Token, error) {
+	if userID == mockToken.UserID {
+		return []*models.Token{mockToken}, nil
+	}
+	return []*models.Token{}, nil
+}
+
+func (m *TokenModel) Delete(ctx context.Context, id, userID int) error {
+	if id == mockToken.ID && userID == mockToken.UserID {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *TokenModel) Authenticate(ctx context.Context, plaintext string) (int, error) {
+	if plaintext == "MOCKTOKENMOCKTOKENMOCKTOKENMOCKT" {
+		return 1, nil
+	}
+	return 0, models.ErrInvalidCredentials
+}
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"time"
 
 	"snippetbox.flaviogalon.github.io/internal/models"
@@ -8,7 +9,7 @@ import (
 
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
 	switch email {
 	case "dupe@example.com":
 		return models.ErrDuplicateEmail
@@ -17,14 +18,14 @@ func (m *UserModel) Insert(name, email, password string) error {
 	}
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
 	if email == "alice@example.com" && password == "pa$$word" {
 		return 1, nil
 	}
 	return 0, models.ErrInvalidCredentials
 }
 
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
 	switch id {
 	case 1:
 		return true, nil
@@ -33,7 +34,7 @@ func (m *UserModel) Exists(id int) (bool, error) {
 	}
 }
 
-func (m *UserModel) Get(id int) (*models.User, error) {
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
 	if id == 1 {
 		user := &models.User{
 			ID:      1,
@@ -46,6 +47,6 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	return nil, models.ErrNoRecord
 }
 
-func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
 	return nil
 }
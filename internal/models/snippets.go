@@ -1,64 +1,423 @@
 package models
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Snippet struct {
-	ID      int
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	UserName   string    `json:"author"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Language   string    `json:"language"`
+	Format     string    `json:"format"`
+	Visibility string    `json:"visibility"`
+	Slug       string    `json:"slug,omitempty"`
+	Created    time.Time `json:"created"`
+	// Zero for snippets that never expire
+	Expires time.Time `json:"expires"`
+	// True when an access password is required to read the content
+	Protected      bool   `json:"protected"`
+	HashedPassword []byte `json:"-"`
+	// Views left before the snippet is burned, nil when unlimited
+	RemainingViews *int `json:"remaining_views"`
+}
+
+// Encode the expiry of snippets that never expire as null rather than as the
+// zero time
+func (s Snippet) MarshalJSON() ([]byte, error) {
+	type snippetJSON Snippet
+
+	var expires *time.Time
+	if !s.Expires.IsZero() {
+		expires = &s.Expires
+	}
+
+	return json.Marshal(struct {
+		snippetJSON
+		Expires *time.Time `json:"expires"`
+	}{snippetJSON(s), expires})
+}
+
+// Report whether fetching the snippet used up its last view
+func (s *Snippet) LastView() bool {
+	return s.RemainingViews != nil && *s.RemainingViews == 0
+}
+
+// Return nil if the password unlocks the snippet or ErrInvalidCredentials
+func (s *Snippet) CheckPassword(password string) error {
+	return CheckPassword(s.HashedPassword, password)
+}
+
+// Return the identifier used in the snippet URLs: the unguessable slug for
+// unlisted snippets, the ID otherwise
+func (s *Snippet) Ref() string {
+	if s.Visibility == VisibilityUnlisted {
+		return s.Slug
+	}
+	return strconv.Itoa(s.ID)
+}
+
+// Fields of a snippet its author can set and edit
+type SnippetFields struct {
 	Title   string
 	Content string
-	Created time.Time
+	// Used for syntax highlighting, empty for plain text
+	Language string
+	// How the content is rendered: FormatPlain or FormatMarkdown
+	Format string
+	// VisibilityPublic, VisibilityUnlisted or VisibilityPrivate
+	Visibility string
+}
+
+// Rendering formats of the snippet content
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// Who can see a snippet. Public snippets are listed and searchable, unlisted
+// ones are only reachable through their slug and private ones only by their
+// author.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Settings of a snippet only chosen at its creation
+type SnippetOptions struct {
+	// When the snippet expires, zero for never
 	Expires time.Time
+	// Optional access password, stored as a bcrypt hash
+	Password string
+	// Number of times the snippet can be read, 0 for unlimited
+	MaxViews int
 }
 
 type SnippedModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	Insert(ctx context.Context, userID int, fields SnippetFields, options SnippetOptions) (int, error)
+	Get(ctx context.Context, id int) (*Snippet, error)
+	Peek(ctx context.Context, id int) (*Snippet, error)
+	PeekBySlug(ctx context.Context, slug string) (*Snippet, error)
+	List(ctx context.Context, filter SnippetFilter) (*SnippetPage, error)
+	Search(ctx context.Context, query string, limit int) ([]*Snippet, error)
+	ListByUser(ctx context.Context, userID int) ([]*Snippet, error)
+	Update(ctx context.Context, id int, fields SnippetFields) error
+	Delete(ctx context.Context, id int) error
+	DeleteExpired(ctx context.Context, limit int) (int, error)
 }
 
-type SnippetModel struct {
-	DB *sql.DB
+// Sort orders accepted by SnippetFilter
+const (
+	SortNewest = "newest"
+	SortOldest = "oldest"
+)
+
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+// Options of a snippet listing. Pages are fetched with keyset pagination:
+// IDs are assigned in creation order, so the ID of the last (or first)
+// snippet of a page is the cursor to the next (or previous) one.
+type SnippetFilter struct {
+	// Return the snippets coming after this ID in the sort order
+	After int
+	// Return the snippets coming before this ID in the sort order
+	Before int
+	// Defaults to DefaultPageSize, capped to MaxPageSize
+	PageSize int
+	// SortNewest (default) or SortOldest
+	Sort           string
+	IncludeExpired bool
 }
 
-// Insert a new snippet into the database
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	sqlQuery := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// Number of snippets of a page: PageSize, defaulting to DefaultPageSize and
+// capped to MaxPageSize
+func (f SnippetFilter) Size() int {
+	if f.PageSize < 1 {
+		return DefaultPageSize
+	}
+	return min(f.PageSize, MaxPageSize)
+}
 
-	result, err := m.DB.Exec(sqlQuery, title, content, expires)
-	if err != nil {
-		return 0, err
+// Report whether the snippets are read by increasing ID. Walking backwards
+// means reading the sort order in reverse from the cursor, then flipping the
+// results back.
+func (f SnippetFilter) Ascending() bool {
+	return (f.Sort == SortOldest) != (f.Before > 0)
+}
+
+// ID the snippets are read from, excluded, or 0 to start from the first one
+func (f SnippetFilter) Cursor() int {
+	if f.Before > 0 {
+		return f.Before
 	}
+	return f.After
+}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, err
+// Build the page out of the snippets read from the cursor in the Ascending
+// order. Reading Size()+1 of them tells whether there's another page in that
+// direction.
+func (f SnippetFilter) Page(snippets []*Snippet) *SnippetPage {
+	backwards := f.Before > 0
+
+	hasMore := len(snippets) > f.Size()
+	if hasMore {
+		snippets = snippets[:f.Size()]
+	}
+	if backwards {
+		slices.Reverse(snippets)
 	}
 
-	return int(id), nil
+	page := &SnippetPage{Snippets: snippets}
+	if len(snippets) == 0 {
+		return page
+	}
+
+	first, last := snippets[0].ID, snippets[len(snippets)-1].ID
+	if backwards {
+		page.Next = last
+		if hasMore {
+			page.Prev = first
+		}
+	} else {
+		if hasMore {
+			page.Next = last
+		}
+		if f.After > 0 {
+			page.Prev = first
+		}
+	}
+
+	return page
+}
+
+// A page of snippets and the cursors to its neighbours (0 if there's none)
+type SnippetPage struct {
+	Snippets []*Snippet `json:"snippets"`
+	Next     int        `json:"next,omitempty"`
+	Prev     int        `json:"prev,omitempty"`
 }
 
-// Get a specific snippet by ID
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	sqlQuery := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+type SnippetModel struct {
+	DB      *sql.DB
+	Dialect Dialect
+	// Cost of the access password hashes, defaults to DefaultBcryptCost
+	BcryptCost int
+	// Defaults to DefaultQueryTimeout when 0
+	QueryTimeout time.Duration
+}
 
-	row := m.DB.QueryRow(sqlQuery, id)
-	// Initialize a pointer to an empty instance of a Snippet
+// Columns selected by every snippet query, in the order expected by scanSnippet
+const snippetColumns = `snippets.id, snippets.user_id, users.name, snippets.title,
+	snippets.content, snippets.language, snippets.format, snippets.visibility,
+	snippets.slug, snippets.created, snippets.expires, snippets.hashed_password,
+	snippets.remaining_views`
+
+// Excludes the expired snippets, given the current time as argument. A NULL
+// expiry means the snippet is permanent.
+const notExpired = `(snippets.expires IS NULL OR snippets.expires > ?)`
+
+// Excludes the snippets whose views have all been used up
+const notBurned = `(snippets.remaining_views IS NULL OR snippets.remaining_views > 0)`
+
+// Anything that can scan a row: *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Copy the values of a row selected with snippetColumns into a new Snippet
+func scanSnippet(row rowScanner) (*Snippet, error) {
 	snippet := &Snippet{}
-	// Copy the values from the returned row (if one) to the struct
+	var expires sql.NullTime
+	var remainingViews sql.NullInt64
 	err := row.Scan(
 		&snippet.ID,
+		&snippet.UserID,
+		&snippet.UserName,
 		&snippet.Title,
 		&snippet.Content,
+		&snippet.Language,
+		&snippet.Format,
+		&snippet.Visibility,
+		&snippet.Slug,
 		&snippet.Created,
-		&snippet.Expires,
+		&expires,
+		&snippet.HashedPassword,
+		&remainingViews,
+	)
+	if err != nil {
+		return nil, err
+	}
+	snippet.Expires = expires.Time
+	snippet.Protected = len(snippet.HashedPassword) > 0
+	if remainingViews.Valid {
+		views := int(remainingViews.Int64)
+		snippet.RemainingViews = &views
+	}
+	return snippet, nil
+}
+
+// Return a random URL-safe identifier of 22 characters (128 bits)
+func NewSlug() (string, error) {
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// Insert a new snippet owned by the given user into the database
+func (m *SnippetModel) Insert(ctx context.Context, userID int, fields SnippetFields, options SnippetOptions) (int, error) {
+	slug, err := NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	// NULL when the snippet has no access password
+	var hashedPassword []byte
+	if options.Password != "" {
+		hashedPassword, err = HashPassword(options.Password, m.BcryptCost)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// NULL when the snippet can be read any number of times
+	var remainingViews sql.NullInt64
+	if options.MaxViews > 0 {
+		remainingViews = sql.NullInt64{Int64: int64(options.MaxViews), Valid: true}
+	}
+
+	// NULL when the snippet never expires
+	expires := sql.NullTime{
+		Time:  options.Expires.UTC().Truncate(time.Second),
+		Valid: !options.Expires.IsZero(),
+	}
+
+	sqlQuery := `INSERT INTO snippets (user_id, title, content, language, format,
+	visibility, slug, hashed_password, remaining_views, created, expires)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	return m.Dialect.insert(
+		ctx,
+		m.DB,
+		sqlQuery,
+		userID,
+		fields.Title,
+		fields.Content,
+		fields.Language,
+		fields.Format,
+		fields.Visibility,
+		slug,
+		hashedPassword,
+		remainingViews,
+		Now(),
+		expires,
 	)
+}
+
+// Get a specific snippet by ID, whatever its visibility, to read its content.
+// This uses up one of the views of a view limited snippet: the counter is
+// decremented before the snippet is read, and the row stays locked until the
+// end of the transaction, so concurrent readers can't share a view.
+func (m *SnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	// The timeout covers the whole transaction
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// No-op once the transaction is committed
+	defer tx.Rollback()
+
+	now := Now()
+
+	// Only matches the view limited snippets with views left
+	sqlQuery := `UPDATE snippets SET remaining_views = remaining_views - 1
+	WHERE ` + notExpired + ` AND snippets.remaining_views > 0 AND snippets.id = ?`
+
+	result, err := tx.ExecContext(ctx, m.Dialect.rebind(sqlQuery), now, id)
+	if err != nil {
+		return nil, err
+	}
+	consumed, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
 
+	sqlQuery = `SELECT ` + snippetColumns + ` FROM snippets
+	INNER JOIN users ON users.id = snippets.user_id
+	WHERE ` + notExpired + ` AND snippets.id = ?`
+	// The view just used up may have been the last one
+	if consumed == 0 {
+		sqlQuery += ` AND ` + notBurned
+	}
+
+	snippet, err := scanSnippet(tx.QueryRowContext(ctx, m.Dialect.rebind(sqlQuery), now, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return snippet, nil
+}
+
+// Get a specific snippet by ID, whatever its visibility, without counting a
+// view
+func (m *SnippetModel) Peek(ctx context.Context, id int) (*Snippet, error) {
+	sqlQuery := `SELECT ` + snippetColumns + ` FROM snippets
+	INNER JOIN users ON users.id = snippets.user_id
+	WHERE ` + notExpired + ` AND ` + notBurned + `
+	AND snippets.id = ?`
+
+	return m.get(ctx, sqlQuery, Now(), id)
+}
+
+// Get a specific public or unlisted snippet by slug without counting a view
+func (m *SnippetModel) PeekBySlug(ctx context.Context, slug string) (*Snippet, error) {
+	sqlQuery := `SELECT ` + snippetColumns + ` FROM snippets
+	INNER JOIN users ON users.id = snippets.user_id
+	WHERE ` + notExpired + ` AND ` + notBurned + `
+	AND snippets.slug = ? AND snippets.visibility IN ('public', 'unlisted')`
+
+	return m.get(ctx, sqlQuery, Now(), slug)
+}
+
+// Run a query selecting snippetColumns which returns at most one row
+func (m *SnippetModel) get(ctx context.Context, sqlQuery string, args ...any) (*Snippet, error) {
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	// Copy the values from the returned row (if one) to a new Snippet
+	snippet, err := scanSnippet(m.DB.QueryRowContext(ctx, m.Dialect.rebind(sqlQuery), args...))
 	if err != nil {
 		// If the DB driver returned no rows
 		if errors.Is(err, sql.ErrNoRows) {
@@ -72,12 +431,220 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	return snippet, nil
 }
 
-// Return the 10 most recent snippets
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	sqlQuery := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+// Update the editable fields of an unexpired snippet. A public snippet made
+// unlisted or private gets a new slug, so that the one shown while it was
+// public no longer reaches it. Returns ErrNoRecord when the snippet doesn't
+// exist or expired.
+func (m *SnippetModel) Update(ctx context.Context, id int, fields SnippetFields) error {
+	slug, err := NewSlug()
+	if err != nil {
+		return err
+	}
+
+	// MySQL assigns the columns from left to right, so the slug is set while
+	// visibility still holds the previous value
+	sqlQuery := `UPDATE snippets SET
+	slug = CASE WHEN visibility = 'public' AND ? <> 'public' THEN ? ELSE slug END,
+	title = ?, content = ?, language = ?, format = ?, visibility = ?
+	WHERE ` + notExpired + ` AND snippets.id = ?`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(
+		ctx,
+		m.Dialect.rebind(sqlQuery),
+		fields.Visibility,
+		slug,
+		fields.Title,
+		fields.Content,
+		fields.Language,
+		fields.Format,
+		fields.Visibility,
+		Now(),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	// MySQL only counts the rows it changed, which an edit saving the same
+	// values doesn't, so the snippet may still be there
+	if m.Dialect == MySQL || m.Dialect == "" {
+		var exists bool
+		sqlQuery = `SELECT EXISTS(SELECT true FROM snippets WHERE ` + notExpired + ` AND snippets.id = ?)`
+		err = m.DB.QueryRowContext(ctx, m.Dialect.rebind(sqlQuery), Now(), id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+	}
+
+	return ErrNoRecord
+}
+
+// Delete a snippet by ID
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	sqlQuery := "DELETE FROM snippets WHERE id = ?"
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(sqlQuery), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Delete at most limit snippets that expired or whose views were all used up,
+// returning how many were deleted
+func (m *SnippetModel) DeleteExpired(ctx context.Context, limit int) (int, error) {
+	const expired = `expires <= ? OR remaining_views <= 0`
+
+	// MySQL can't limit an IN subquery, the others can't limit a DELETE
+	var sqlQuery string
+	if m.Dialect == MySQL || m.Dialect == "" {
+		sqlQuery = `DELETE FROM snippets WHERE ` + expired + ` LIMIT ?`
+	} else {
+		sqlQuery = `DELETE FROM snippets WHERE id IN (
+		SELECT id FROM snippets WHERE ` + expired + ` LIMIT ?)`
+	}
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(sqlQuery), Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
+
+// Return a page of snippets matching the filter
+func (m *SnippetModel) List(ctx context.Context, filter SnippetFilter) (*SnippetPage, error) {
+	// Only public snippets are listed
+	conditions := []string{"snippets.visibility = 'public'", notBurned}
+	args := []any{}
+
+	if !filter.IncludeExpired {
+		conditions = append(conditions, notExpired)
+		args = append(args, Now())
+	}
+
+	ascending := filter.Ascending()
+	if cursor := filter.Cursor(); cursor > 0 {
+		if ascending {
+			conditions = append(conditions, "snippets.id > ?")
+		} else {
+			conditions = append(conditions, "snippets.id < ?")
+		}
+		args = append(args, cursor)
+	}
+
+	sqlQuery := `SELECT ` + snippetColumns + ` FROM snippets
+	INNER JOIN users ON users.id = snippets.user_id
+	WHERE ` + strings.Join(conditions, " AND ")
+	if ascending {
+		sqlQuery += " ORDER BY snippets.id ASC"
+	} else {
+		sqlQuery += " ORDER BY snippets.id DESC"
+	}
+	// Fetching an extra row tells whether there's another page in that direction
+	sqlQuery += " LIMIT ?"
+	args = append(args, filter.Size()+1)
+
+	snippets, err := m.query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter.Page(snippets), nil
+}
+
+// Return the unexpired public snippets whose title or content match the
+// query, most relevant first. Password protected and view limited snippets
+// are left out as matching them would leak their content.
+//
+// MySQL and PostgreSQL use their full-text search, SQLite falls back to
+// matching the whole query as a substring, newest first.
+func (m *SnippetModel) Search(ctx context.Context, query string, limit int) ([]*Snippet, error) {
+	var match, relevance string
+	args := []any{Now()}
+	switch m.Dialect {
+	case SQLite:
+		match = `(snippets.title LIKE ? ESCAPE '\' OR snippets.content LIKE ? ESCAPE '\')`
+		pattern := "%" + likeEscaper.Replace(query) + "%"
+		args = append(args, pattern, pattern)
+	case Postgres:
+		document := `to_tsvector('english', snippets.title || ' ' || snippets.content)`
+		match = document + ` @@ plainto_tsquery('english', ?)`
+		relevance = `ts_rank(` + document + `, plainto_tsquery('english', ?)) DESC, `
+		args = append(args, query, query)
+	default:
+		match = `MATCH(snippets.title, snippets.content) AGAINST(? IN NATURAL LANGUAGE MODE)`
+		relevance = match + ` DESC, `
+		args = append(args, query, query)
+	}
+
+	sqlQuery := `SELECT ` + snippetColumns + ` FROM snippets
+	INNER JOIN users ON users.id = snippets.user_id
+	WHERE ` + notExpired + `
+	AND snippets.visibility = 'public'
+	AND snippets.hashed_password IS NULL AND snippets.remaining_views IS NULL
+	AND ` + match + `
+	ORDER BY ` + relevance + `snippets.id DESC
+	LIMIT ?`
+
+	return m.query(ctx, sqlQuery, append(args, limit)...)
+}
+
+// Escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Return all the unexpired snippets created by a user, newest first,
+// whatever their visibility
+func (m *SnippetModel) ListByUser(ctx context.Context, userID int) ([]*Snippet, error) {
+	sqlQuery := `SELECT ` + snippetColumns + ` FROM snippets
+	INNER JOIN users ON users.id = snippets.user_id
+	WHERE ` + notExpired + ` AND ` + notBurned + `
+	AND snippets.user_id = ?
+	ORDER BY snippets.id DESC`
+
+	return m.query(ctx, sqlQuery, Now(), userID)
+}
+
+// Run a query selecting snippetColumns and collect every returned row
+func (m *SnippetModel) query(ctx context.Context, sqlQuery string, args ...any) ([]*Snippet, error) {
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
 
-	rows, err := m.DB.Query(sqlQuery)
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(sqlQuery), args...)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -86,20 +653,13 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 		}
 	}
 
-	// Guaranteeing that the DB connection will be freed when `Latest()` finishes
+	// Guaranteeing that the DB connection will be freed when the query finishes
 	defer rows.Close()
 
 	snippets := []*Snippet{}
 
 	for rows.Next() {
-		snippet := &Snippet{}
-		err = rows.Scan(
-			&snippet.ID,
-			&snippet.Title,
-			&snippet.Content,
-			&snippet.Created,
-			&snippet.Expires,
-		)
+		snippet, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
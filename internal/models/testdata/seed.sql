@@ -0,0 +1,9 @@
+INSERT INTO
+    users (name, email, hashed_password, created)
+VALUES
+    (
+        'Alice Jones',
+        'alice@example.com',
+        '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
+        '2022-01-01 10:00:00'
+    );
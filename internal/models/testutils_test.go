@@ -1,19 +1,84 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
 // Return a new test DB instance which will be cleaned-up when the test is finished.
 func newTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("mysql", "test_web:pass@/test_snippetbox?parseTime=true&multiStatements=true")
+	db, err := sql.Open("mysql", "test_web:pass@/test_snippetbox?parseTime=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setupTestDB(t, db, MySQL)
+
+	t.Cleanup(func() {
+		teardownTestDB(t, db, MySQL)
+		db.Close()
+	})
+
+	return db
+}
+
+// Return a new PostgreSQL test DB instance which will be cleaned-up when the
+// test is finished. The test is skipped unless SNIPPETBOX_TEST_POSTGRES_DSN
+// holds the DSN of a database the tests may wipe.
+func newTestPostgresDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("SNIPPETBOX_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("models: SNIPPETBOX_TEST_POSTGRES_DSN not set, skipping PostgreSQL tests")
+	}
+
+	db, err := sql.Open(Postgres.DriverName(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setupTestDB(t, db, Postgres)
+
+	t.Cleanup(func() {
+		teardownTestDB(t, db, Postgres)
+		db.Close()
+	})
+
+	return db
+}
+
+// Return a new SQLite test DB, stored in a temporary file removed when the
+// test is finished. It needs no database server, so it's always available.
+func newTestSQLiteDB(t *testing.T) *sql.DB {
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") +
+		"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	setupTestDB(t, db, SQLite)
+
+	return db
+}
+
+// Create the schema with the migrations and load the test data
+func setupTestDB(t *testing.T, db *sql.DB, dialect Dialect) {
+	ctx := context.Background()
+	m := MigrationModel{DB: db, Dialect: dialect}
+	_, err := m.Up(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	script, err := os.ReadFile("./testdata/setup.sql")
+	script, err := os.ReadFile("./testdata/seed.sql")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -22,20 +87,24 @@ func newTestDB(t *testing.T) *sql.DB {
 	if err != nil {
 		t.Fatal(err)
 	}
+}
 
-	t.Cleanup(func() {
-		script, err := os.ReadFile("./testdata/teardown.sql")
-		if err != nil {
-			t.Fatal(err)
+// Revert every migration, leaving an empty database for the next test
+func teardownTestDB(t *testing.T, db *sql.DB, dialect Dialect) {
+	ctx := context.Background()
+	m := MigrationModel{DB: db, Dialect: dialect}
+	for {
+		_, err := m.Down(ctx)
+		if errors.Is(err, ErrNoRecord) {
+			break
 		}
-
-		_, err = db.Exec(string(script))
 		if err != nil {
 			t.Fatal(err)
 		}
+	}
 
-		db.Close()
-	})
-
-	return db
+	_, err := db.Exec("DROP TABLE schema_migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
 }
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+type Token struct {
+	ID      int       `json:"id"`
+	UserID  int       `json:"user_id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	// Only known right after the token creation, the DB stores its hash
+	Plaintext string `json:"token,omitempty"`
+}
+
+type TokenModelInterface interface {
+	New(ctx context.Context, userID int, name string) (*Token, error)
+	ListByUser(ctx context.Context, userID int) ([]*Token, error)
+	Delete(ctx context.Context, id, userID int) error
+	Authenticate(ctx context.Context, plaintext string) (int, error)
+}
+
+type TokenModel struct {
+	DB      *sql.DB
+	Dialect Dialect
+	// Defaults to DefaultQueryTimeout when 0
+	QueryTimeout time.Duration
+}
+
+// Return the hex encoded SHA-256 hash under which a token is stored
+func HashToken(plaintext string) string {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(hash[:])
+}
+
+// Generate a new random API token for a user and store its hash
+func (m *TokenModel) New(ctx context.Context, userID int, name string) (*Token, error) {
+	randomBytes := make([]byte, 20)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		UserID:    userID,
+		Name:      name,
+		Created:   Now(),
+		Plaintext: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes),
+	}
+
+	sqlQuery := `INSERT INTO tokens (user_id, name, hash, created)
+	VALUES(?, ?, ?, ?)`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	token.ID, err = m.Dialect.insert(ctx, m.DB, sqlQuery, userID, name, HashToken(token.Plaintext), token.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Return the tokens of a user, newest first
+func (m *TokenModel) ListByUser(ctx context.Context, userID int) ([]*Token, error) {
+	sqlQuery := `SELECT id, user_id, name, created FROM tokens
+	WHERE user_id = ? ORDER BY id DESC`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(sqlQuery), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*Token{}
+
+	for rows.Next() {
+		token := &Token{}
+		err = rows.Scan(&token.ID, &token.UserID, &token.Name, &token.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Revoke one of the user's tokens
+func (m *TokenModel) Delete(ctx context.Context, id, userID int) error {
+	sqlQuery := "DELETE FROM tokens WHERE id = ? AND user_id = ?"
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(sqlQuery), id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Return the ID of the user owning the token
+func (m *TokenModel) Authenticate(ctx context.Context, plaintext string) (int, error) {
+	var userID int
+	sqlQuery := "SELECT user_id FROM tokens WHERE hash = ?"
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(sqlQuery), HashToken(plaintext)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
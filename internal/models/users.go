@@ -1,55 +1,78 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"strings"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	ID             int
-	Name           string
-	Email          string
-	HashedPassword []byte
-	Created        time.Time
+	ID             int       `json:"id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	HashedPassword []byte    `json:"-"`
+	Created        time.Time `json:"created"`
 }
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
-	Authenticate(email, password string) (int, error)
-	Exists(id int) (bool, error)
-	Get(id int) (*User, error)
-	PasswordUpdate(id int, currentPassword, newPassword string) error
+	Insert(ctx context.Context, name, email, password string) error
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	Get(ctx context.Context, id int) (*User, error)
+	PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Dialect Dialect
+	// Defaults to DefaultBcryptCost when 0
+	BcryptCost int
+	// Defaults to DefaultQueryTimeout when 0
+	QueryTimeout time.Duration
+}
+
+// Work factor of the bcrypt password hashes
+const DefaultBcryptCost = 12
+
+// Hash a password with the given bcrypt cost, or DefaultBcryptCost when 0
+func HashPassword(password string, cost int) ([]byte, error) {
+	if cost == 0 {
+		cost = DefaultBcryptCost
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), cost)
+}
+
+// Compare a password to its hash, returning ErrInvalidCredentials when they
+// don't match
+func CheckPassword(hashedPassword []byte, password string) error {
+	err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrInvalidCredentials
+	}
+	return err
 }
 
 // Create a user
-func (m *UserModel) Insert(name, email, password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	hashedPassword, err := HashPassword(password, m.BcryptCost)
 	if err != nil {
 		return err
 	}
 
 	sql := `INSERT INTO users (name, email, hashed_password, created)
-	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	VALUES(?, ?, ?, ?)`
+
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
 
-	_, err = m.DB.Exec(sql, name, email, string(hashedPassword))
+	_, err = m.DB.ExecContext(ctx, m.Dialect.rebind(sql), name, email, string(hashedPassword), Now())
 	if err != nil {
-		var mysqlError *mysql.MySQLError
-		if errors.As(err, &mysqlError) {
-			// Check for duplicate email
-			// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_dup_entry
-			if mysqlError.Number == 1062 &&
-				strings.Contains(mysqlError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+		// Check for duplicate email
+		if isDuplicateKey(err, "users_uc_email", "users.email") {
+			return ErrDuplicateEmail
 		}
 		return err
 	}
@@ -57,11 +80,14 @@ func (m *UserModel) Insert(name, email, password string) error {
 }
 
 // Authenticate an user
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
 	user := User{}
 	sqlQuery := "SELECT id, hashed_password FROM users where email = ?"
 
-	err := m.DB.QueryRow(sqlQuery, email).Scan(&user.ID, &user.HashedPassword)
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(sqlQuery), email).Scan(&user.ID, &user.HashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -70,33 +96,35 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 		}
 	}
 
-	err = bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(password))
+	err = CheckPassword(user.HashedPassword, password)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return 0, ErrInvalidCredentials
-		} else {
-			return 0, err
-		}
+		return 0, err
 	}
 
 	return user.ID, nil
 }
 
 // Return true if there's a user with the given id
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
 	var exists bool
 	sqlQuery := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
 
-	err := m.DB.QueryRow(sqlQuery, id).Scan(&exists)
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(sqlQuery), id).Scan(&exists)
 	return exists, err
 }
 
 // Get a new user
-func (m *UserModel) Get(id int) (*User, error) {
+func (m *UserModel) Get(ctx context.Context, id int) (*User, error) {
 	var user User
 	sqlQuery := "SELECT id, name, email, created FROM users where id = ?"
 
-	err := m.DB.QueryRow(sqlQuery, id).Scan(&user.ID, &user.Name, &user.Email, &user.Created)
+	ctx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(sqlQuery), id).Scan(&user.ID, &user.Name, &user.Email, &user.Created)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -109,30 +137,33 @@ func (m *UserModel) Get(id int) (*User, error) {
 }
 
 // Update an user's password
-func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
 	var currentHashedPassword []byte
 
+	// Each query gets its own timeout, the password hashing in between is slow
+	queryCtx, cancel := withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
 	sqlQuery := "SELECT hashed_password FROM users WHERE id = ?"
-	err := m.DB.QueryRow(sqlQuery, id).Scan(&currentHashedPassword)
+	err := m.DB.QueryRowContext(queryCtx, m.Dialect.rebind(sqlQuery), id).Scan(&currentHashedPassword)
 	if err != nil {
 		return err
 	}
 
-	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	err = CheckPassword(currentHashedPassword, currentPassword)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return ErrInvalidCredentials
-		} else {
-			return err
-		}
+		return err
 	}
 
-	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(currentPassword), 12)
+	hashedNewPassword, err := HashPassword(newPassword, m.BcryptCost)
 	if err != nil {
 		return err
 	}
 
+	queryCtx, cancel = withTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+
 	sqlQuery = "UPDATE users SET hashed_password = ? WHERE id = ?"
-	_, err = m.DB.Exec(sqlQuery, hashedNewPassword, id)
+	_, err = m.DB.ExecContext(queryCtx, m.Dialect.rebind(sqlQuery), hashedNewPassword, id)
 	return err
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"snippetbox.flaviogalon.github.io/internal/assert"
@@ -10,6 +12,7 @@ func TestUserModelExists(t *testing.T) {
 	if testing.Short() {
 		t.Skip("models: skipping integration tests")
 	}
+	ctx := context.Background()
 	tests := []struct {
 		name   string
 		userID int
@@ -36,12 +39,51 @@ func TestUserModelExists(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			db := newTestDB(t)
 
-			m := UserModel{db}
+			m := UserModel{DB: db}
 
-			exists, err := m.Exists(tt.userID)
+			exists, err := m.Exists(ctx, tt.userID)
 
 			assert.Equal(t, exists, tt.want)
 			assert.NilError(t, err)
 		})
 	}
 }
+
+func TestUserModelPasswordUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration tests")
+	}
+	testUserModelPasswordUpdate(t, UserModel{DB: newTestDB(t), BcryptCost: 4})
+}
+
+func TestSQLiteUserModelPasswordUpdate(t *testing.T) {
+	testUserModelPasswordUpdate(t, UserModel{DB: newTestSQLiteDB(t), Dialect: SQLite, BcryptCost: 4})
+}
+
+func TestPostgresUserModelPasswordUpdate(t *testing.T) {
+	testUserModelPasswordUpdate(t, UserModel{DB: newTestPostgresDB(t), Dialect: Postgres, BcryptCost: 4})
+}
+
+// Change the password of a new user, then log in with the new one.
+// PasswordUpdate used to store the hash of the current password instead.
+func testUserModelPasswordUpdate(t *testing.T, m UserModel) {
+	ctx := context.Background()
+
+	err := m.Insert(ctx, "Bob", "bob@example.com", "pa55word")
+	assert.NilError(t, err)
+	id, err := m.Authenticate(ctx, "bob@example.com", "pa55word")
+	assert.NilError(t, err)
+
+	err = m.PasswordUpdate(ctx, id, "wrong", "new pa55word")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	err = m.PasswordUpdate(ctx, id, "pa55word", "new pa55word")
+	assert.NilError(t, err)
+
+	got, err := m.Authenticate(ctx, "bob@example.com", "new pa55word")
+	assert.NilError(t, err)
+	assert.Equal(t, got, id)
+
+	_, err = m.Authenticate(ctx, "bob@example.com", "pa55word")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+}